@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/raidancampbell/gopotato/expr"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// unlocked achievements for every ROM, kept in the working directory
+	ACHIEVEMENT_STORE = "gopotato_achievements.json"
+	// how long an unlock is shown on screen
+	ACHIEVEMENT_TOAST_TIME = 4 * time.Second
+)
+
+// achievement is read from a ROM's achievement file, a JSON list beside the ROM:
+//
+//	[{"id": "survivor", "name": "Survivor", "description": "Keep 5 lives for a second",
+//	  "condition": "mem[0x2E0] >= 5 for 60 frames"}]
+//
+// the condition is an expression, see expr.Expr, that must hold for the given number of consecutive frames, or one
+type achievement struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Condition   string `json:"condition"`
+
+	cond   expr.Expr
+	frames int
+	streak int // consecutive frames the condition has held
+}
+
+// achievementTracker evaluates a ROM's achievements once a frame and records the ones unlocked
+type achievementTracker struct {
+	sync.Mutex
+	rom      string // the ROM's file name, which the store is keyed by
+	defs     []*achievement
+	unlocked map[string]time.Time // by ID
+	toasts   []achievementToast   // unlocks still on screen
+}
+
+type achievementToast struct {
+	msg   string
+	until time.Time
+}
+
+// "<expression> for <n> frames"
+var achievementDuration = regexp.MustCompile(`^(.*?)\s+for\s+(\d+)\s+frames?$`)
+
+// unlocks are written here by every machine, so they take turns
+var achievementStoreMutex sync.Mutex
+
+// the achievement file for a ROM sits beside it, with a .achievements.json extension
+func achievementPath(romPath string) string {
+	return strings.TrimSuffix(romPath, filepath.Ext(romPath)) + ".achievements.json"
+}
+
+// reads the ROM's achievement definitions and what's been unlocked so far.  with no definition file there's nothing to track
+func loadAchievements(romPath string) (*achievementTracker, error) {
+	path := achievementPath(romPath)
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	t := &achievementTracker{rom: filepath.Base(romPath)}
+	if err := json.Unmarshal(b, &t.defs); err != nil {
+		return nil, fmt.Errorf("malformed achievement file %s: %v", path, err)
+	}
+	for _, a := range t.defs {
+		src, frames := a.Condition, 1
+		if match := achievementDuration.FindStringSubmatch(src); match != nil {
+			src = match[1]
+			frames, _ = strconv.Atoi(match[2])
+		}
+		if a.cond, err = expr.Parse(src); err != nil {
+			return nil, fmt.Errorf("%s: achievement %q: %v", path, a.ID, err)
+		}
+		a.frames = frames
+	}
+
+	store, err := readAchievementStore()
+	if err != nil {
+		return nil, err
+	}
+	t.unlocked = store[t.rom]
+	if t.unlocked == nil {
+		t.unlocked = map[string]time.Time{}
+	}
+	return t, nil
+}
+
+// unlock times by ROM file name and achievement ID
+func readAchievementStore() (map[string]map[string]time.Time, error) {
+	store := map[string]map[string]time.Time{}
+	b, err := ioutil.ReadFile(ACHIEVEMENT_STORE)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &store); err != nil {
+		return nil, fmt.Errorf("malformed achievement store %s: %v", ACHIEVEMENT_STORE, err)
+	}
+	return store, nil
+}
+
+// adds an unlock to the store on disk, keeping anything other machines have unlocked meanwhile
+func saveAchievement(rom, id string, at time.Time) error {
+	achievementStoreMutex.Lock()
+	defer achievementStoreMutex.Unlock()
+	store, err := readAchievementStore()
+	if err != nil {
+		return err
+	}
+	if store[rom] == nil {
+		store[rom] = map[string]time.Time{}
+	}
+	store[rom][id] = at
+	b, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(ACHIEVEMENT_STORE, b, 0644)
+}
+
+// checks every locked achievement against this frame.  callers must hold the machine's cpuMutex
+func (t *achievementTracker) evaluate(m *machine) {
+	t.Lock()
+	defer t.Unlock()
+	for _, a := range t.defs {
+		if _, ok := t.unlocked[a.ID]; ok {
+			continue
+		}
+		if a.cond(m) == 0 {
+			a.streak = 0
+			continue
+		}
+		a.streak++
+		if a.streak < a.frames {
+			continue
+		}
+		now := time.Now()
+		t.unlocked[a.ID] = now
+		t.toasts = append(t.toasts, achievementToast{msg: "achievement unlocked: " + a.Name, until: now.Add(ACHIEVEMENT_TOAST_TIME)})
+		// written from another goroutine, so the disk and other machines' unlocks don't hold up this one's CPU
+		go func(id string) {
+			if err := saveAchievement(t.rom, id, now); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to save achievement %q: %v\n", id, err)
+			}
+		}(a.ID)
+	}
+}
+
+// the unlock to show on screen now, if any
+func (t *achievementTracker) toast() string {
+	if t == nil {
+		return ""
+	}
+	t.Lock()
+	defer t.Unlock()
+	now := time.Now()
+	for len(t.toasts) > 0 && now.After(t.toasts[0].until) {
+		t.toasts = t.toasts[1:]
+		// the next one gets its full time on screen
+		if len(t.toasts) > 0 {
+			t.toasts[0].until = now.Add(ACHIEVEMENT_TOAST_TIME)
+		}
+	}
+	if len(t.toasts) == 0 {
+		return ""
+	}
+	return t.toasts[0].msg
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/raidancampbell/gopotato/expr"
+	"os"
+	"testing"
+	"time"
+)
+
+// an unlock is shown straight away and saved without the machine waiting on the store, which here is busy
+// with another machine's unlock
+func TestUnlockDoesNotWaitForTheStore(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(dir)
+
+	cond, err := expr.Parse("mem[0x200] == 0x12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker := &achievementTracker{
+		rom:      "test.ch8",
+		defs:     []*achievement{{ID: "start", Name: "Start", cond: cond, frames: 1}},
+		unlocked: map[string]time.Time{},
+	}
+	m := newMachine()
+	m.mem[0x200] = 0x12
+
+	achievementStoreMutex.Lock()
+	evaluated := make(chan bool)
+	go func() {
+		m.cpuMutex.Lock()
+		tracker.evaluate(m)
+		m.cpuMutex.Unlock()
+		evaluated <- true
+	}()
+	select {
+	case <-evaluated:
+	case <-time.After(time.Second):
+		achievementStoreMutex.Unlock()
+		t.Fatal("evaluate waited for the achievement store")
+	}
+	if got := tracker.toast(); got != "achievement unlocked: Start" {
+		t.Errorf("toast = %q", got)
+	}
+	achievementStoreMutex.Unlock()
+
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		achievementStoreMutex.Lock()
+		store, err := readAchievementStore()
+		achievementStoreMutex.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := store["test.ch8"]["start"]; ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the unlock was never saved")
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cheat is a named set of codes, switched on and off together.  a cheat file lists one per line:
+//
+//	# comments start with a hash
+//	Infinite lives: freeze address 0x3A4 to 0x09
+//	Skip the intro: patch opcode at 0x2F0 to 0x1300
+//	Both at once: freeze 0x3A4 0x09; freeze 0x3A5 0x00
+//
+// a freeze holds memory at its value every frame and whenever the program writes to it.
+// a patch overwrites memory once, when the ROM is loaded or the cheat is switched on, and is undone when switched off
+type cheat struct {
+	name    string
+	codes   []cheatCode
+	enabled bool
+}
+
+type cheatCode struct {
+	freeze bool // otherwise a patch
+	addr   uint16
+	value  []byte
+	saved  []byte // what a patch overwrote
+}
+
+// words that make codes read like sentences, ignored by the parser
+var cheatFillerWords = map[string]bool{"address": true, "opcode": true, "byte": true, "bytes": true, "at": true, "to": true, "=": true}
+
+// the cheat file for a ROM sits beside it, with a .cheats extension
+func cheatPath(romPath string) string {
+	return strings.TrimSuffix(romPath, filepath.Ext(romPath)) + ".cheats"
+}
+
+// reads the cheat file at the given path.  a missing file is not an error
+func loadCheats(path string) ([]*cheat, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cheats, err := parseCheats(f)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cheat file %s: %v", path, err)
+	}
+	return cheats, nil
+}
+
+func parseCheats(r io.Reader) ([]*cheat, error) {
+	var cheats []*cheat
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sep := strings.LastIndex(line, ":")
+		if sep < 0 {
+			return nil, fmt.Errorf("line %d: expected \"name: code\"", lineNum)
+		}
+		c := &cheat{name: strings.TrimSpace(line[:sep])}
+		for _, src := range strings.Split(line[sep+1:], ";") {
+			code, err := parseCheatCode(src)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+			c.codes = append(c.codes, code)
+		}
+		cheats = append(cheats, c)
+	}
+	return cheats, scanner.Err()
+}
+
+// parses "freeze 0x3A4 0x09" or "patch 0x2F0 0x1300".  hex values are as many bytes wide as they're written,
+// so 0x0009 is two bytes; decimal values are one byte
+func parseCheatCode(src string) (cheatCode, error) {
+	var words []string
+	for _, word := range strings.Fields(strings.ToLower(src)) {
+		if !cheatFillerWords[word] {
+			words = append(words, word)
+		}
+	}
+	if len(words) != 3 || (words[0] != "freeze" && words[0] != "patch") {
+		return cheatCode{}, fmt.Errorf("expected \"freeze <address> <value>\" or \"patch <address> <value>\", got %q", strings.TrimSpace(src))
+	}
+	addr, err := strconv.ParseUint(words[1], 0, 16)
+	if err != nil || addr >= uint64(len(ram{})) {
+		return cheatCode{}, fmt.Errorf("malformed address %q", words[1])
+	}
+
+	var value []byte
+	if digits := strings.TrimPrefix(words[2], "0x"); digits != words[2] {
+		if len(digits)%2 == 1 {
+			digits = "0" + digits
+		}
+		for idx := 0; idx < len(digits); idx += 2 {
+			b, err := strconv.ParseUint(digits[idx:idx+2], 16, 8)
+			if err != nil {
+				return cheatCode{}, fmt.Errorf("malformed value %q", words[2])
+			}
+			value = append(value, byte(b))
+		}
+	} else {
+		b, err := strconv.ParseUint(words[2], 10, 8)
+		if err != nil {
+			return cheatCode{}, fmt.Errorf("malformed value %q", words[2])
+		}
+		value = []byte{byte(b)}
+	}
+	if len(value) == 0 || int(addr)+len(value) > len(ram{}) {
+		return cheatCode{}, fmt.Errorf("value %q doesn't fit at 0x%03X", words[2], addr)
+	}
+	return cheatCode{freeze: words[0] == "freeze", addr: uint16(addr), value: value}, nil
+}
+
+// installs the cheats, applying the enabled ones
+func (m *machine) setCheats(cheats []*cheat) {
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	m.cheats = cheats
+	m.patchCheats()
+	m.freezeCheats()
+	m.applyFreezes()
+}
+
+// switches the numbered cheat on or off, applying or undoing its patches straight away
+func (m *machine) toggleCheat(idx int) {
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	c := m.cheats[idx]
+	c.enabled = !c.enabled
+	for codeIdx := range c.codes {
+		code := &c.codes[codeIdx]
+		if code.freeze {
+			continue
+		}
+		if c.enabled {
+			m.patch(code)
+		} else if code.saved != nil {
+			copy(m.mem[code.addr:], code.saved)
+		}
+	}
+	m.freezeCheats()
+	m.applyFreezes()
+}
+
+// the names of the cheats and whether each is on.  safe to call while the machine runs
+func (m *machine) cheatList() ([]string, []bool) {
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	names := make([]string, len(m.cheats))
+	enabled := make([]bool, len(m.cheats))
+	for idx, c := range m.cheats {
+		names[idx], enabled[idx] = c.name, c.enabled
+	}
+	return names, enabled
+}
+
+// applies the enabled cheats' patches to freshly loaded memory.  callers must hold cpuMutex
+func (m *machine) patchCheats() {
+	for _, c := range m.cheats {
+		if !c.enabled {
+			continue
+		}
+		for codeIdx := range c.codes {
+			code := &c.codes[codeIdx]
+			if !code.freeze {
+				m.patch(code)
+			}
+		}
+	}
+}
+
+// callers must hold cpuMutex
+func (m *machine) patch(code *cheatCode) {
+	code.saved = append([]byte(nil), m.mem[code.addr:int(code.addr)+len(code.value)]...)
+	copy(m.mem[code.addr:], code.value)
+}
+
+// collects the addresses held by enabled freezes.  callers must hold cpuMutex
+func (m *machine) freezeCheats() {
+	m.frozen = map[uint16]byte{}
+	for _, c := range m.cheats {
+		if !c.enabled {
+			continue
+		}
+		for _, code := range c.codes {
+			if code.freeze {
+				for idx, b := range code.value {
+					m.frozen[code.addr+uint16(idx)] = b
+				}
+			}
+		}
+	}
+}
+
+// writes every frozen address, once a frame.  callers must hold cpuMutex
+func (m *machine) applyFreezes() {
+	for addr, b := range m.frozen {
+		m.mem[addr] = b
+	}
+}
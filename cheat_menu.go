@@ -0,0 +1,58 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"fmt"
+	"github.com/faiface/pixel/pixelgl"
+)
+
+// the window's cheat menu, opened with tab.  the machine is paused while it's open
+type cheatMenu struct {
+	pausingPanel
+	cursor int
+}
+
+func (menu *cheatMenu) update(win *pixelgl.Window, m *machine) bool {
+	if win.JustPressed(pixelgl.KeyTab) || (menu.open && win.JustPressed(pixelgl.KeyEscape)) {
+		menu.toggle(m)
+		return true
+	}
+	if !menu.open {
+		return false
+	}
+
+	names, _ := m.cheatList()
+	switch {
+	case win.JustPressed(pixelgl.KeyUp) || win.Repeated(pixelgl.KeyUp):
+		menu.cursor--
+	case win.JustPressed(pixelgl.KeyDown) || win.Repeated(pixelgl.KeyDown):
+		menu.cursor++
+	case win.JustPressed(pixelgl.KeyEnter) || win.JustPressed(pixelgl.KeySpace):
+		if menu.cursor < len(names) {
+			m.toggleCheat(menu.cursor)
+		}
+	}
+	menu.cursor = clampCursor(menu.cursor, len(names))
+	return true
+}
+
+func (menu *cheatMenu) draw(win *pixelgl.Window, m *machine) {
+	names, enabled := m.cheatList()
+	lines := []string{"cheats | up/down to move, enter to toggle, tab to close", ""}
+	if len(names) == 0 {
+		lines = append(lines, "no cheats for this ROM")
+	}
+	for idx, name := range names {
+		cursor, check := " ", " "
+		if idx == menu.cursor {
+			cursor = ">"
+		}
+		if enabled[idx] {
+			check = "x"
+		}
+		lines = append(lines, fmt.Sprintf("%s [%s] %s", cursor, check, name))
+	}
+	drawPanel(win, lines)
+}
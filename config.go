@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/raidancampbell/gopotato/gym"
+	"os"
+	"path/filepath"
+)
+
+// optional settings file, read from the working directory
+const CONFIG_FILE = "gopotato.json"
+
+type config struct {
+	Gamepads gamepadProfile `json:"gamepads"`
+	Palette  *themeSetting  `json:"palette"`
+	Flicker  *flickerConfig `json:"flicker"`
+	Filter   string         `json:"filter"`
+	// per-ROM overrides, keyed by the ROM's file name
+	ROMs map[string]romConfig `json:"roms"`
+}
+
+// settings that apply while a single ROM is running
+type romConfig struct {
+	Gamepads gamepadProfile `json:"gamepads"`
+	Env      gym.Config     `json:"env"`
+	Palette  *themeSetting  `json:"palette"`
+	Flicker  *flickerConfig `json:"flicker"`
+	Filter   string         `json:"filter"`
+}
+
+// built-in settings, used for anything the config file leaves out
+var defaultConfig = config{
+	Gamepads: defaultGamepadProfile,
+	ROMs: map[string]romConfig{
+		"Pong (1 player).ch8":                         {Gamepads: pongGamepadProfile},
+		"Pong 2 (Pong hack) [David Winter, 1997].ch8": {Gamepads: pongGamepadProfile},
+		"Pong [Paul Vervalin, 1990].ch8":              {Gamepads: pongGamepadProfile},
+	},
+}
+
+// settings for the running ROM
+var romCfg romConfig
+
+// reads the config file at the given path, layered over the defaults.  a missing file is not an error
+func loadConfig(path string) (config, error) {
+	cfg := defaultConfig
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	defer f.Close()
+
+	var fileCfg config
+	if err := json.NewDecoder(f).Decode(&fileCfg); err != nil {
+		return cfg, fmt.Errorf("malformed config file %s: %v", path, err)
+	}
+	if fileCfg.Gamepads != nil {
+		cfg.Gamepads = fileCfg.Gamepads
+	}
+	if fileCfg.Palette != nil {
+		cfg.Palette = fileCfg.Palette
+	}
+	if fileCfg.Flicker != nil {
+		cfg.Flicker = fileCfg.Flicker
+	}
+	if fileCfg.Filter != "" {
+		cfg.Filter = fileCfg.Filter
+	}
+	roms := map[string]romConfig{}
+	for name, rc := range cfg.ROMs {
+		roms[name] = rc
+	}
+	for name, rc := range fileCfg.ROMs {
+		roms[name] = rc
+	}
+	cfg.ROMs = roms
+	return cfg, nil
+}
+
+// resolves the settings for the ROM at the given path, falling back to the global settings
+func (c config) forROM(romPath string) romConfig {
+	rc := c.ROMs[filepath.Base(romPath)]
+	if rc.Gamepads == nil {
+		rc.Gamepads = c.Gamepads
+	}
+	if rc.Palette == nil {
+		rc.Palette = c.Palette
+	}
+	if rc.Flicker == nil {
+		rc.Flicker = c.Flicker
+	}
+	if rc.Filter == "" {
+		rc.Filter = c.Filter
+	}
+	return rc
+}
@@ -3,13 +3,27 @@ package main
 import (
 	"encoding/binary"
 	"fmt"
+	"github.com/raidancampbell/gopotato/expr"
+	"io/ioutil"
 	"sync"
 	"time"
 )
 
+const DEBUG_OUTPUT = false
+
+// instructions executed per 60hz frame, matching the 512hz ticker's 16 instructions a tick
+const CYCLES_PER_FRAME = 16 * 512 / 60
+
+// how fast the machine runs while fast-forward is held
+const FAST_FORWARD_SPEED = 8
+
+// the speed multipliers the machine can be set to, slowest first
+var speeds = []float64{0.25, 0.5, 1, 2, 4, 8}
+
 type reg *byte
 
-var (
+// machine is one CHIP-8 computer.  any number of them can run side by side
+type machine struct {
 	v0, v1, v2, v3, v4, v5, v6, v7, v8, v9, va, vb, vc, vd, ve reg
 
 	vf     reg // flag register
@@ -18,125 +32,377 @@ var (
 	pc     uint16
 	sp     byte
 	stack  [16]uint16
-)
 
-func init() {
-	pc = 0x200
-	initRAM()
-	v0 = new(byte)
-	v1 = new(byte)
-	v2 = new(byte)
-	v3 = new(byte)
-	v4 = new(byte)
-	v5 = new(byte)
-	v6 = new(byte)
-	v7 = new(byte)
-	v8 = new(byte)
-	v9 = new(byte)
-	va = new(byte)
-	vb = new(byte)
-	vc = new(byte)
-	vd = new(byte)
-	ve = new(byte)
-	vf = new(byte)
-	dt = new(byte)
-	st = new(byte)
-	disp = display{
-		Mutex: &sync.Mutex{},
-	}
-	keyPress = make(chan byte)
+	mem  ram
+	disp display
+
+	kbMutex      sync.Mutex
+	keys         [16]bool // hex keypad state, indexed by nibble
+	keyWait      keyWaitState
+	inputSources []inputSource // every source that pollForKeys merges into the keypad state
+
+	cpuMutex sync.Mutex // held while instructions execute or the timers tick
+	paused   bool
+	rom      []byte // the program last loaded, for resets
+	romPath  string // where rom was read from, for power cycles, if it came from a file
+
+	speed       float64 // multiplier on the CPU and timer rates
+	fastForward bool
+	cycleBudget float64 // instructions owed to the CPU, which at fractional speeds may not be whole each tick
+	timerBudget float64 // timer ticks owed, likewise
+
+	cheats []*cheat
+	frozen map[uint16]byte // addresses held by enabled freeze codes
+
+	achievements *achievementTracker // nil when the ROM has none
+	recorder     *recorder
+	access       *memAccess // nil unless a memory viewer is watching
+	draws        *drawTrace // nil unless the draw heatmap is watching
+
+	quit chan struct{}
+}
+
+// behaviours that differ between CHIP-8 interpreters
+var quirks struct {
+	// complete Fx0A when a key is pressed rather than when it is released, as most modern interpreters do
+	keyWaitOnPress bool
+}
+
+func newMachine() *machine {
+	m := &machine{
+		v0: new(byte),
+		v1: new(byte),
+		v2: new(byte),
+		v3: new(byte),
+		v4: new(byte),
+		v5: new(byte),
+		v6: new(byte),
+		v7: new(byte),
+		v8: new(byte),
+		v9: new(byte),
+		va: new(byte),
+		vb: new(byte),
+		vc: new(byte),
+		vd: new(byte),
+		ve: new(byte),
+		vf: new(byte),
+		dt: new(byte),
+		st: new(byte),
+		disp: display{
+			Mutex: &sync.Mutex{},
+		},
+		speed: 1,
+		quit:  make(chan struct{}),
+	}
+	m.reset()
+	return m
+}
+
+// returns the machine to its power-on state, with the font loaded and no program
+func (m *machine) reset() {
+	m.pc = 0x200
+	m.i = 0
+	m.sp = 0
+	m.stack = [16]uint16{}
+	for nibble := byte(0); nibble <= 0x0F; nibble++ {
+		*m.numToReg(nibble) = 0x00
+	}
+	*m.dt = 0x00
+	*m.st = 0x00
+	m.initRAM()
+
+	m.disp.Lock()
+	m.disp.fb = framebuffer{}
+	m.disp.updated = true
+	m.disp.Unlock()
+
+	m.kbMutex.Lock()
+	m.keyWait = keyWaitState{}
+	m.kbMutex.Unlock()
+}
+
+// starts the CPU and timers running in the background
+func (m *machine) start() {
+	go m.timerTick()
+	go m.tick()
+}
+
+// halts the CPU and timers for good
+func (m *machine) stop() {
+	close(m.quit)
 }
 
 // emulate the CPU at 512hz
-func tick() {
+func (m *machine) tick() {
 	tim := time.NewTicker(1953 * time.Microsecond)
+	defer tim.Stop()
 	for {
 		select {
+		case <-m.quit:
+			return
 		case <-tim.C:
-			for itr := 0; itr < 16; itr++ {
-				opWord := binary.BigEndian.Uint16([]byte{mem[pc], mem[pc+1]})
-				var op opcode
-				found := false
-				for opItr := range opcodes {
-					if opcodes[opItr].matches(opWord) {
-						op = opcodes[opItr]
-						found = true
-						break
-					}
-				}
-				if !found {
-					panic(fmt.Sprintf("failed to find opcode %x", opWord))
+			m.cpuMutex.Lock()
+			if !m.paused {
+				for m.cycleBudget += 16 * m.currentSpeed(); m.cycleBudget >= 1; m.cycleBudget-- {
+					m.step()
 				}
-				if DEBUG_OUTPUT {
-					fmt.Printf("executing opcode %x at address %x as: %s\n", opWord, pc, op.name)
-				}
-				op.exec(opWord)
 			}
+			m.cpuMutex.Unlock()
+		}
+	}
+}
+
+// fetches, decodes and executes the instruction at pc.  callers must hold cpuMutex
+func (m *machine) step() {
+	opWord := binary.BigEndian.Uint16([]byte{m.mem[m.pc], m.mem[m.pc+1]})
+	if m.access != nil {
+		m.access.counts.executed[m.pc]++
+		m.access.counts.executed[m.pc+1]++
+	}
+	op, found := decode(opWord)
+	if !found {
+		panic(fmt.Sprintf("failed to find opcode %x", opWord))
+	}
+	if DEBUG_OUTPUT {
+		fmt.Printf("executing opcode %x at address %x as: %s\n", opWord, m.pc, op.name)
+	}
+	op.exec(m, opWord)
+}
+
+// the opcode an instruction word executes as
+func decode(opWord uint16) (opcode, bool) {
+	for opItr := range opcodes {
+		if opcodes[opItr].matches(opWord) {
+			return opcodes[opItr], true
 		}
 	}
+	return opcode{}, false
 }
 
 // timerTick controls
-func timerTick() {
+func (m *machine) timerTick() {
 	tim := time.NewTicker(16667 * time.Microsecond)
+	defer tim.Stop()
 
 	for {
 		select {
+		case <-m.quit:
+			return
 		case <-tim.C:
-			pollForKeys() // abusively putting this in the timer code.  we don't need to poll that often
-			if *dt != 0x00 {
-				*dt--
-			}
-			if *st != 0x00 {
-				*st--
+			m.cpuMutex.Lock()
+			if !m.paused {
+				for m.timerBudget += m.currentSpeed(); m.timerBudget >= 1; m.timerBudget-- {
+					m.decrementTimers()
+				}
 			}
+			m.cpuMutex.Unlock()
 		}
 	}
 }
 
-func numToReg(nibble byte) reg {
+// one 60hz timer tick.  callers must hold cpuMutex
+func (m *machine) decrementTimers() {
+	m.applyFreezes()
+	m.pollForKeys() // abusively putting this in the timer code.  we don't need to poll that often
+	if *m.dt != 0x00 {
+		*m.dt--
+	}
+	if *m.st != 0x00 {
+		*m.st--
+	}
+	m.disp.vblank()
+	if m.access != nil {
+		m.access.endFrame()
+	}
+	if m.draws != nil {
+		m.draws.endFrame()
+	}
+	if m.achievements != nil {
+		m.achievements.evaluate(m)
+	}
+	if m.recorder != nil {
+		m.recorder.capture(m.disp.snapshot(), *m.st != 0x00)
+	}
+}
+
+// stops or restarts the CPU and timers without losing any state
+func (m *machine) setPaused(paused bool) {
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	m.paused = paused
+}
+
+func (m *machine) isPaused() bool {
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	return m.paused
+}
+
+// executes n instructions without ticking the timers, for single stepping while paused
+func (m *machine) stepInstructions(n int) {
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	m.pollForKeys()
+	for itr := 0; itr < n; itr++ {
+		m.step()
+	}
+}
+
+// runs n 60hz frames as fast as possible: a frame's worth of instructions, then a timer tick
+func (m *machine) runFrames(n int) {
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	for frame := 0; frame < n; frame++ {
+		for itr := 0; itr < CYCLES_PER_FRAME; itr++ {
+			m.step()
+		}
+		m.decrementTimers()
+	}
+}
+
+// resets the machine and loads a new program, safely while it's running
+func (m *machine) load(rom []byte) {
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	m.reset()
+	m.loadROMBytes(rom)
+}
+
+// reloads the last program into a freshly reset machine
+func (m *machine) restart() {
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	m.reset()
+	m.loadROMBytes(m.rom)
+}
+
+// turns the machine off and on again: a reset with the program read afresh from its file, and nothing
+// left over on screen from before.  falls back to the program in memory if the file can't be read
+func (m *machine) powerCycle() error {
+	m.cpuMutex.Lock()
+	path, rom := m.romPath, m.rom
+	m.cpuMutex.Unlock()
+	var err error
+	if path != "" {
+		var b []byte
+		if b, err = ioutil.ReadFile(path); err == nil {
+			rom = b
+		}
+	}
+
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	m.reset()
+	m.disp.Lock()
+	m.disp.shade = shades{}
+	m.disp.lastFB = framebuffer{}
+	m.disp.Unlock()
+	m.cycleBudget, m.timerBudget = 0, 0
+	m.loadROMBytes(rom)
+	return err
+}
+
+// the speed the machine is running at, counting fast-forward.  callers must hold cpuMutex
+func (m *machine) currentSpeed() float64 {
+	if m.fastForward && m.speed < FAST_FORWARD_SPEED {
+		return FAST_FORWARD_SPEED
+	}
+	return m.speed
+}
+
+// moves the speed multiplier the given number of steps through speeds, returning the new speed
+func (m *machine) changeSpeed(steps int) float64 {
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	idx := 0
+	for itr, speed := range speeds {
+		if speed <= m.speed {
+			idx = itr
+		}
+	}
+	idx += steps
+	if idx < 0 {
+		idx = 0
+	} else if idx >= len(speeds) {
+		idx = len(speeds) - 1
+	}
+	m.speed = speeds[idx]
+	return m.speed
+}
+
+func (m *machine) setFastForward(on bool) {
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	m.fastForward = on
+}
+
+// the speed multiplier, and whether fast-forward is held
+func (m *machine) speedState() (float64, bool) {
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	return m.speed, m.fastForward
+}
+
+func (m *machine) numToReg(nibble byte) reg {
 	if nibble > 0x0F {
 		panic(fmt.Sprintf("malformed nibble given to numToReg: %x", nibble))
 	}
 
 	switch nibble {
 	case 0x00:
-		return v0
+		return m.v0
 	case 0x01:
-		return v1
+		return m.v1
 	case 0x02:
-		return v2
+		return m.v2
 	case 0x03:
-		return v3
+		return m.v3
 	case 0x04:
-		return v4
+		return m.v4
 	case 0x05:
-		return v5
+		return m.v5
 	case 0x06:
-		return v6
+		return m.v6
 	case 0x07:
-		return v7
+		return m.v7
 	case 0x08:
-		return v8
+		return m.v8
 	case 0x09:
-		return v9
+		return m.v9
 	case 0x0A:
-		return va
+		return m.va
 	case 0x0B:
-		return vb
+		return m.vb
 	case 0x0C:
-		return vc
+		return m.vc
 	case 0x0D:
-		return vd
+		return m.vd
 	case 0x0E:
-		return ve
+		return m.ve
 	case 0x0F:
-		return vf
+		return m.vf
 	default:
 	}
 	panic(fmt.Sprintf("impossible nibble given to numToReg: %x", nibble))
 }
 
+// the register's value, for memory expressions.  callers must hold cpuMutex or have the machine stopped
+func (m *machine) Register(r expr.Register) int {
+	switch r {
+	case expr.I:
+		return int(m.i)
+	case expr.PC:
+		return int(m.pc)
+	case expr.SP:
+		return int(m.sp)
+	case expr.DT:
+		return int(*m.dt)
+	case expr.ST:
+		return int(*m.st)
+	}
+	return int(*m.numToReg(byte(r - expr.V0)))
+}
+
 func intToHex(i int) byte {
 	switch i {
 	case 0:
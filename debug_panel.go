@@ -0,0 +1,102 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"fmt"
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"github.com/faiface/pixel/pixelgl"
+	"github.com/faiface/pixel/text"
+	"strings"
+)
+
+const (
+	// the debug panel's width in window pixels, and the size of a pixel in its sprite bitmap
+	DEBUG_PANEL_WIDTH  = 270
+	DEBUG_SPRITE_PIXEL = 4
+	// instructions disassembled from pc on
+	DEBUG_DISASSEMBLY_LINES = 6
+	// the most bytes a sprite can be drawn from
+	MAX_SPRITE_HEIGHT = 15
+)
+
+// debugPanel shows the machine's registers, stack, the code at pc and the sprite at I down the right of the window,
+// toggled with F6, with the display moved over to make room.  it stays up while the game runs, refreshed every frame
+type debugPanel struct {
+	open bool
+}
+
+func (panel *debugPanel) update(win *pixelgl.Window) {
+	if win.JustPressed(pixelgl.KeyF6) {
+		panel.open = !panel.open
+	}
+}
+
+// the window width the panel takes while it's open
+func (panel *debugPanel) width() float64 {
+	if panel.open {
+		return DEBUG_PANEL_WIDTH
+	}
+	return 0
+}
+
+func (panel *debugPanel) draw(win *pixelgl.Window, m *machine) {
+	if !panel.open {
+		return
+	}
+	state := m.saveState()
+	bounds := win.Bounds()
+	left := bounds.Max.X - DEBUG_PANEL_WIDTH
+
+	bg := imdraw.New(nil)
+	bg.Color = pixel.RGBA{A: 0.8}
+	bg.Push(pixel.V(left, bounds.Min.Y), bounds.Max)
+	bg.Rectangle(0)
+	bg.Draw(win)
+
+	txt := text.New(pixel.V(left+8, bounds.H()-8-text.Atlas7x13.Ascent()), text.Atlas7x13)
+	for row := 0; row < 4; row++ {
+		var regs []string
+		for col := 0; col < 4; col++ {
+			nibble := row*4 + col
+			regs = append(regs, fmt.Sprintf("V%X %02X", nibble, state.V[nibble]))
+		}
+		fmt.Fprintln(txt, strings.Join(regs, "  "))
+	}
+	fmt.Fprintf(txt, "I %04X  PC %04X  SP %X  DT %02X  ST %02X\n", state.I, state.PC, state.SP, state.DT, state.ST)
+	stack := "stack"
+	for idx := 0; idx < int(state.SP) && idx < len(state.Stack); idx++ {
+		stack += fmt.Sprintf(" %04X", state.Stack[idx])
+	}
+	fmt.Fprintln(txt, stack)
+	fmt.Fprintln(txt)
+	for idx, line := range disassembleAt(state.Mem, state.PC, DEBUG_DISASSEMBLY_LINES) {
+		cursor := " "
+		if idx == 0 {
+			cursor = ">"
+		}
+		fmt.Fprintln(txt, cursor+" "+line)
+	}
+	fmt.Fprintln(txt)
+	fmt.Fprintln(txt, "sprite at I")
+	txt.Draw(win, pixel.IM)
+
+	// the sprite's rows from the top down, below the text
+	top := txt.Dot.Y + text.Atlas7x13.Ascent()
+	sprite := imdraw.New(nil)
+	sprite.Color = currentPalette()[1]
+	for row := 0; row < MAX_SPRITE_HEIGHT && int(state.I)+row < len(state.Mem); row++ {
+		b := state.Mem[int(state.I)+row]
+		for bit := 0; bit < 8; bit++ {
+			if b&(0x80>>uint(bit)) == 0 {
+				continue
+			}
+			min := pixel.V(left+8+float64(bit*DEBUG_SPRITE_PIXEL), top-float64((row+1)*DEBUG_SPRITE_PIXEL))
+			sprite.Push(min, min.Add(pixel.V(DEBUG_SPRITE_PIXEL, DEBUG_SPRITE_PIXEL)))
+			sprite.Rectangle(0)
+		}
+	}
+	sprite.Draw(win)
+}
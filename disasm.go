@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// the instruction word as assembly, filled in from its opcode's name, e.g. 0x6A05 as "LD VA, 0x05".
+// words that aren't instructions come out as data
+func disassemble(opWord uint16) string {
+	op, ok := decode(opWord)
+	if !ok {
+		return fmt.Sprintf("DW 0x%04X", opWord)
+	}
+	asm := op.name[strings.Index(op.name, ": ")+2:]
+	return strings.NewReplacer(
+		" {, Vy}", fmt.Sprintf(", V%X", opWord>>4&0xF),
+		"Vx", fmt.Sprintf("V%X", opWord>>8&0xF),
+		"Vy", fmt.Sprintf("V%X", opWord>>4&0xF),
+		"addr", fmt.Sprintf("0x%03X", opWord&0xFFF),
+		"byte", fmt.Sprintf("0x%02X", opWord&0xFF),
+		"nibble", fmt.Sprintf("%d", opWord&0xF),
+	).Replace(asm)
+}
+
+// the instructions from addr on, one line each with its address and word, as far as memory goes
+func disassembleAt(mem []byte, addr uint16, count int) []string {
+	var lines []string
+	for itr := 0; itr < count && int(addr)+1 < len(mem); itr++ {
+		opWord := uint16(mem[addr])<<8 | uint16(mem[addr+1])
+		lines = append(lines, fmt.Sprintf("%04X  %04X  %s", addr, opWord, disassemble(opWord)))
+		addr += 2
+	}
+	return lines
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDisassemble(t *testing.T) {
+	tests := []struct {
+		opWord uint16
+		want   string
+	}{
+		{0x00E0, "CLS"},
+		{0x00EE, "RET"},
+		{0x1234, "JP 0x234"},
+		{0x2ABC, "CALL 0xABC"},
+		{0x3A05, "SE VA, 0x05"},
+		{0x4B10, "SNE VB, 0x10"},
+		{0x5120, "SE V1, V2"},
+		{0x6A05, "LD VA, 0x05"},
+		{0x7FFF, "ADD VF, 0xFF"},
+		// x and y the other way round tell them apart
+		{0x8120, "LD V1, V2"},
+		{0x8211, "OR V2, V1"},
+		{0x8CD2, "AND VC, VD"},
+		{0x8DC3, "XOR VD, VC"},
+		{0x8344, "ADD V3, V4"},
+		{0x8125, "SUB V1, V2"},
+		{0x8126, "SHR V1, V2"},
+		{0x8217, "SUBN V2, V1"},
+		{0x812E, "SHL V1, V2"},
+		{0x9AB0, "SNE VA, VB"},
+		{0xA2F0, "LD I, 0x2F0"},
+		{0xB300, "JP V0, 0x300"},
+		{0xC1FF, "RND V1, 0xFF"},
+		{0xD125, "DRW V1, V2, 5"},
+		{0xD21F, "DRW V2, V1, 15"},
+		{0xE29E, "SKP V2"},
+		{0xE3A1, "SKNP V3"},
+		{0xF407, "LD V4, DT"},
+		{0xF50A, "LD V5, K"},
+		{0xF615, "LD DT, V6"},
+		{0xF718, "LD ST, V7"},
+		{0xF81E, "ADD I, V8"},
+		{0xF929, "LD F, V9"},
+		{0xFA33, "LD B, VA"},
+		{0xFB55, "LD [I], VB"},
+		{0xFC65, "LD VC, [I]"},
+		// words that aren't instructions
+		{0x0000, "DW 0x0000"},
+		{0x5121, "DW 0x5121"},
+		{0x8128, "DW 0x8128"},
+		{0xE1FF, "DW 0xE1FF"},
+		{0xFFFF, "DW 0xFFFF"},
+	}
+	for _, test := range tests {
+		if got := disassemble(test.opWord); got != test.want {
+			t.Errorf("disassemble(%04X) = %q, want %q", test.opWord, got, test.want)
+		}
+	}
+}
+
+// lines run from the address for as many instructions as asked, stopping short where memory ends
+func TestDisassembleAt(t *testing.T) {
+	mem := []byte{0x00, 0xE0, 0x6A, 0x05, 0x12, 0x00, 0xFF}
+	want := []string{
+		"0000  00E0  CLS",
+		"0002  6A05  LD VA, 0x05",
+		"0004  1200  JP 0x200",
+	}
+	if got := disassembleAt(mem, 0, 10); !reflect.DeepEqual(got, want) {
+		t.Errorf("disassembleAt(mem, 0, 10) = %q, want %q", got, want)
+	}
+	if got := disassembleAt(mem, 2, 1); !reflect.DeepEqual(got, want[1:2]) {
+		t.Errorf("disassembleAt(mem, 2, 1) = %q, want %q", got, want[1:2])
+	}
+}
@@ -1,10 +1,8 @@
 package main
 
 import (
-	"github.com/faiface/pixel"
-	"github.com/faiface/pixel/imdraw"
-	"github.com/faiface/pixel/pixelgl"
-	"golang.org/x/image/colornames"
+	"image"
+	"image/color"
 	"sync"
 )
 
@@ -17,80 +15,87 @@ const (
 type display struct {
 	*sync.Mutex
 	fb      framebuffer
-	prevFB  framebuffer
 	updated bool
-	window  *pixelgl.Window
-}
-type framebuffer [XRES][YRES]bool
-
-var disp display
 
-func initDisp() {
-	cfg := pixelgl.WindowConfig{
-		Title:  "gopotato",
-		Bounds: pixel.R(0, 0, XRES*SCALE, YRES*SCALE),
-		VSync:  true,
-	}
-	win, err := pixelgl.NewWindow(cfg)
-	if err != nil {
-		panic(err)
-	}
-	win.Clear(colornames.Black)
-	disp.window = win
+	// what's shown after anti-flicker, worked out at each vblank
+	shade  shades
+	lastFB framebuffer // fb at the last vblank
 }
+type framebuffer [XRES][YRES]bool
 
 // draws the given sprite on the display, with the top left corner at the given origin
-// returns whether any pixels were erased by the draw
-func drawSprite(sprite []byte, originX, originY byte) bool {
+// returns the pixels erased by the draw, if any
+func (disp *display) drawSprite(sprite []byte, originX, originY byte) []image.Point {
 	disp.Lock()
 	defer disp.Unlock()
 	disp.updated = true
-	didErase := false
+	var erased []image.Point
+	eachSpritePixel(sprite, originX, originY, func(x, y int) {
+		if disp.fb[x][y] {
+			erased = append(erased, image.Pt(x, y))
+		}
+		// pixels are drawn via xor, so a set sprite pixel flips the display's
+		disp.fb[x][y] = !disp.fb[x][y]
+	})
+	return erased
+}
+
+// calls visit with each display pixel the sprite sets, wrapping round the edges of the display
+func eachSpritePixel(sprite []byte, originX, originY byte, visit func(x, y int)) {
 	for y, spriteByte := range sprite {
 		for bitIdx := byte(0); bitIdx < 8; bitIdx++ {
-			drawPixel := (0x80>>bitIdx)&spriteByte > 0
-			// cheap short circuit around the xor before evaluating hundreds of modulo ops
-			if !drawPixel {
+			// cheap short circuit around the modulo ops for the pixels the sprite leaves alone
+			if (0x80>>bitIdx)&spriteByte == 0 {
 				continue
 			}
-
-			isLit := disp.fb[(originX+bitIdx)%XRES][(originY+byte(y))%YRES]
-			if isLit && drawPixel {
-				didErase = true
-			}
-			// pixels are drawn via xor. with no xor logical operator, we must expand it
-			disp.fb[(originX+bitIdx)%XRES][(originY+byte(y))%YRES] = (drawPixel || isLit) && !(drawPixel && isLit)
+			visit(int(originX+bitIdx)%XRES, int(originY+byte(y))%YRES)
 		}
 	}
-	return didErase
 }
 
-func drawWindow(imd *imdraw.IMDraw) {
+// a copy of the framebuffer, safe to read while the CPU keeps drawing
+func (disp *display) snapshot() framebuffer {
 	disp.Lock()
 	defer disp.Unlock()
-	defer disp.window.Update()
-	if !disp.updated {
-		return
-	}
+	return disp.fb
+}
 
-	for rownum, row := range disp.fb {
-		for colnum, pix := range row {
-			if pix == disp.prevFB[rownum][colnum] {
-				continue
-			}
-			if pix {
-				imd.Color = colornames.White
-			} else {
-				imd.Color = colornames.Black
+// packs the pixels row by row, most significant bit first
+func (fb framebuffer) pack() []byte {
+	bits := make([]byte, XRES*YRES/8)
+	for y := 0; y < YRES; y++ {
+		for x := 0; x < XRES; x++ {
+			if fb[x][y] {
+				idx := y*XRES + x
+				bits[idx/8] |= 0x80 >> uint(idx%8)
 			}
-			// origin according to Pixel is the lower left corner
-			// the CHIP-8 and our framebuffer use the upper left corner
-			imd.Push(pixel.V(float64(rownum*SCALE), float64(YRES*SCALE-(colnum+1)*SCALE)),
-				pixel.V(float64(rownum*SCALE+1*(SCALE-1)), float64(YRES*SCALE-(colnum+1)*SCALE+1*(SCALE-1))))
-			imd.Rectangle(0.)
 		}
 	}
+	return bits
+}
 
-	imd.Draw(disp.window)
-	disp.prevFB = disp.fb
+// the inverse of pack
+func unpackFramebuffer(bits []byte) framebuffer {
+	var fb framebuffer
+	for y := 0; y < YRES; y++ {
+		for x := 0; x < XRES; x++ {
+			idx := y*XRES + x
+			fb[x][y] = idx/8 < len(bits) && bits[idx/8]&(0x80>>uint(idx%8)) != 0
+		}
+	}
+	return fb
+}
+
+// the framebuffer as an image in the active palette, each pixel scaled up to a scale by scale square
+func (fb framebuffer) image(scale int) *image.Paletted {
+	p := currentPalette()
+	img := image.NewPaletted(image.Rect(0, 0, XRES*scale, YRES*scale), color.Palette{p[0], p[1], p[2], p[3]})
+	for x := 0; x < XRES*scale; x++ {
+		for y := 0; y < YRES*scale; y++ {
+			if fb[x/scale][y/scale] {
+				img.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+	return img
 }
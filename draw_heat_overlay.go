@@ -0,0 +1,146 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"fmt"
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"github.com/faiface/pixel/pixelgl"
+	"github.com/faiface/pixel/text"
+	"image"
+	"math"
+)
+
+const (
+	// how long a new collision's marker flashes for, in frames
+	COLLISION_FLASH_FRAMES = 20
+	// the most collisions listed for the pixel under the mouse
+	COLLISIONS_LISTED = 5
+)
+
+// drawHeatOverlay tints each display pixel by how many times sprites toggled it over the last DRAW_TRACE_FRAMES
+// frames, toggled with F10, and flashes a marker round the pixels of each sprite draw that collided and set VF.
+// hovering over a pixel, or clicking it to pin it, lists the collisions there with the pc and I that drew them.
+// like the debug panel it stays up while the game runs
+type drawHeatOverlay struct {
+	open     bool
+	pinned   image.Point
+	isPinned bool
+}
+
+func (overlay *drawHeatOverlay) update(win *pixelgl.Window, m *machine, scr *screen) {
+	if win.JustPressed(pixelgl.KeyF10) {
+		overlay.open = !overlay.open
+		overlay.isPinned = false
+		if overlay.open {
+			m.trackDraws()
+		}
+	}
+	if !overlay.open || !win.JustPressed(pixelgl.MouseButtonLeft) {
+		return
+	}
+	p, ok := overlay.pixelAt(win.MousePosition(), scr)
+	overlay.isPinned = ok && !(overlay.isPinned && p == overlay.pinned)
+	overlay.pinned = p
+}
+
+// the display pixel at a window position
+func (overlay *drawHeatOverlay) pixelAt(pos pixel.Vec, scr *screen) (image.Point, bool) {
+	area := scr.area()
+	if !area.Contains(pos) {
+		return image.Point{}, false
+	}
+	scale := scr.scale()
+	x, y := int((pos.X-area.Min.X)/scale), int((area.Max.Y-pos.Y)/scale)
+	if x >= XRES || y >= YRES {
+		return image.Point{}, false
+	}
+	return image.Pt(x, y), true
+}
+
+// the window area of a display pixel, whose row 0 is at the top
+func pixelRect(area pixel.Rect, scale float64, x, y int) pixel.Rect {
+	min := pixel.V(area.Min.X+float64(x)*scale, area.Max.Y-float64(y+1)*scale)
+	return pixel.Rect{Min: min, Max: min.Add(pixel.V(scale, scale))}
+}
+
+func (overlay *drawHeatOverlay) draw(win *pixelgl.Window, m *machine, scr *screen) {
+	if !overlay.open {
+		return
+	}
+	heat := m.drawHeat()
+	area, scale := scr.area(), scr.scale()
+
+	busiest := 0
+	for x := range heat.toggles {
+		for _, n := range heat.toggles[x] {
+			if n > busiest {
+				busiest = n
+			}
+		}
+	}
+	imd := imdraw.New(nil)
+	for x := range heat.toggles {
+		for y, n := range heat.toggles[x] {
+			if n == 0 {
+				continue
+			}
+			// yellow for the odd toggle up to red for the busiest, on a log scale
+			h := math.Log1p(float64(n)) / math.Log1p(float64(busiest))
+			imd.Color = pixel.RGB(1, 1-h, 0).Mul(pixel.Alpha(0.25 + 0.5*h))
+			r := pixelRect(area, scale, x, y)
+			imd.Push(r.Min, r.Max)
+			imd.Rectangle(0)
+		}
+	}
+
+	thickness := math.Max(1, scale/5)
+	for _, c := range heat.collisions {
+		age := heat.frame - c.frame
+		if age < COLLISION_FLASH_FRAMES && (age/4)%2 == 1 {
+			continue
+		}
+		imd.Color = pixel.RGB(1, 0, 1).Mul(pixel.Alpha(1 - float64(age)/DRAW_TRACE_FRAMES))
+		for _, p := range c.pixels {
+			r := pixelRect(area, scale, p.X, p.Y)
+			imd.Push(r.Min, r.Max)
+			imd.Rectangle(thickness)
+		}
+	}
+
+	selected, ok := overlay.pinned, overlay.isPinned
+	if !ok {
+		selected, ok = overlay.pixelAt(win.MousePosition(), scr)
+	}
+	if ok {
+		imd.Color = pixel.RGB(1, 1, 1)
+		r := pixelRect(area, scale, selected.X, selected.Y)
+		imd.Push(r.Min, r.Max)
+		imd.Rectangle(thickness)
+	}
+	imd.Draw(win)
+
+	txt := text.New(pixel.V(8, win.Bounds().H()-8-text.Atlas7x13.Ascent()), text.Atlas7x13)
+	fmt.Fprintf(txt, "sprite draws over the last %d frames | %d collisions\n", DRAW_TRACE_FRAMES, len(heat.collisions))
+	fmt.Fprintln(txt, "click a pixel to pin it, F10 to close")
+	if ok {
+		collisions := heat.collisionsAt(selected.X, selected.Y)
+		fmt.Fprintf(txt, "pixel %d,%d: toggled %d times, %d collisions\n", selected.X, selected.Y, heat.toggles[selected.X][selected.Y], len(collisions))
+		for idx, c := range collisions {
+			if idx == COLLISIONS_LISTED {
+				fmt.Fprintf(txt, "  and %d more\n", len(collisions)-idx)
+				break
+			}
+			fmt.Fprintf(txt, "  PC %04X  I %04X  %d frames ago\n", c.pc, c.i, heat.frame-c.frame)
+		}
+	}
+	bg := imdraw.New(nil)
+	bg.Color = pixel.RGBA{A: 0.7}
+	b := txt.Bounds()
+	bg.Push(b.Min.Sub(pixel.V(4, 4)), b.Max.Add(pixel.V(4, 4)))
+	bg.Rectangle(0)
+	bg.Draw(win)
+	txt.Draw(win, pixel.IM)
+}
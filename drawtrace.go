@@ -0,0 +1,102 @@
+package main
+
+import "image"
+
+const (
+	// frames of sprite drawing kept by the draw tracer
+	DRAW_TRACE_FRAMES = 60
+	// the most collisions kept
+	MAX_COLLISIONS = 64
+)
+
+// drawTrace records, for the draw heatmap, how many times each display pixel was toggled in each of the last
+// frames, and the sprites that collided and set VF
+type drawTrace struct {
+	frames     [DRAW_TRACE_FRAMES][XRES][YRES]uint16 // a ring, one frame per slot
+	current    int                                   // the slot being filled
+	frame      int                                   // frames since tracing began
+	collisions []collision                           // newest last
+}
+
+// a Dxyn that erased pixels, so set VF
+type collision struct {
+	pixels []image.Point // the pixels erased
+	pc, i  uint16        // the instruction, and the sprite's address
+	frame  int
+}
+
+// drawHeat is what the draw tracer saw over its last frames
+type drawHeat struct {
+	toggles    [XRES][YRES]int
+	collisions []collision // newest last
+	frame      int         // the frame being drawn, to age the collisions by
+}
+
+// records a sprite drawn by the instruction at pc, counting the pixels it toggled, and the pixels it erased
+// as drawSprite returned them
+func (t *drawTrace) sprite(sprite []byte, originX, originY byte, erased []image.Point, pc, i uint16) {
+	eachSpritePixel(sprite, originX, originY, func(x, y int) {
+		if t.frames[t.current][x][y] < ^uint16(0) {
+			t.frames[t.current][x][y]++
+		}
+	})
+	if len(erased) > 0 {
+		t.collisions = append(t.collisions, collision{erased, pc, i, t.frame})
+		if len(t.collisions) > MAX_COLLISIONS {
+			t.collisions = t.collisions[len(t.collisions)-MAX_COLLISIONS:]
+		}
+	}
+}
+
+// the end of a frame: the oldest frame's slot is reused, and collisions older than the kept frames dropped
+func (t *drawTrace) endFrame() {
+	t.frame++
+	t.current = (t.current + 1) % DRAW_TRACE_FRAMES
+	t.frames[t.current] = [XRES][YRES]uint16{}
+	for len(t.collisions) > 0 && t.frame-t.collisions[0].frame >= DRAW_TRACE_FRAMES {
+		t.collisions = t.collisions[1:]
+	}
+}
+
+// starts recording sprite draws for the draw heatmap
+func (m *machine) trackDraws() {
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	if m.draws == nil {
+		m.draws = &drawTrace{}
+	}
+}
+
+// the draws over the last DRAW_TRACE_FRAMES frames.  empty unless trackDraws was called
+func (m *machine) drawHeat() drawHeat {
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	var heat drawHeat
+	if m.draws == nil {
+		return heat
+	}
+	for _, frame := range m.draws.frames {
+		for x := range frame {
+			for y, n := range frame[x] {
+				heat.toggles[x][y] += int(n)
+			}
+		}
+	}
+	heat.collisions = append(heat.collisions, m.draws.collisions...)
+	heat.frame = m.draws.frame
+	return heat
+}
+
+// the collisions that erased the pixel, newest first
+func (heat drawHeat) collisionsAt(x, y int) []collision {
+	var found []collision
+	for idx := len(heat.collisions) - 1; idx >= 0; idx-- {
+		for _, p := range heat.collisions[idx].pixels {
+			if p.X == x && p.Y == y {
+				found = append(found, heat.collisions[idx])
+				break
+			}
+		}
+	}
+	return found
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+// a sprite drawn twice over itself, wrapping round the right edge, collides the second time on every pixel it
+// set, and the collision is forgotten once it's older than the frames kept
+func TestDrawTraceCollisions(t *testing.T) {
+	m := newMachine()
+	m.trackDraws()
+	program := []byte{
+		0xA3, 0x00, // LD I, 0x300
+		0x6A, 0x3F, // LD VA, 63
+		0x6B, 0x00, // LD VB, 0
+		0xDA, 0xB2, // DRW VA, VB, 2
+		0xDA, 0xB2, // DRW VA, VB, 2
+	}
+	m.cpuMutex.Lock()
+	copy(m.mem[0x200:], program)
+	m.mem[0x300], m.mem[0x301] = 0xC0, 0x80
+	m.pc = 0x200
+	for itr := 0; itr < len(program)/2; itr++ {
+		m.step()
+	}
+	vf := *m.vf
+	m.cpuMutex.Unlock()
+	if vf != 1 {
+		t.Errorf("VF = %d after drawing over the sprite", vf)
+	}
+
+	heat := m.drawHeat()
+	want := []collision{{
+		pixels: []image.Point{{63, 0}, {0, 0}, {63, 1}},
+		pc:     0x208,
+		i:      0x300,
+	}}
+	if !reflect.DeepEqual(heat.collisions, want) {
+		t.Errorf("collisions = %+v, want %+v", heat.collisions, want)
+	}
+	for _, p := range want[0].pixels {
+		if n := heat.toggles[p.X][p.Y]; n != 2 {
+			t.Errorf("pixel %v toggled %d times, want 2", p, n)
+		}
+	}
+	if found := heat.collisionsAt(0, 0); len(found) != 1 {
+		t.Errorf("%d collisions at 0,0, want 1", len(found))
+	}
+	if found := heat.collisionsAt(1, 0); len(found) != 0 {
+		t.Errorf("%d collisions at 1,0, want none", len(found))
+	}
+
+	m.cpuMutex.Lock()
+	for frame := 0; frame < DRAW_TRACE_FRAMES-1; frame++ {
+		m.decrementTimers()
+	}
+	m.cpuMutex.Unlock()
+	if heat := m.drawHeat(); len(heat.collisions) != 1 || heat.toggles[63][0] != 2 {
+		t.Errorf("the draws were forgotten after %d frames", DRAW_TRACE_FRAMES-1)
+	}
+	m.cpuMutex.Lock()
+	m.decrementTimers()
+	m.cpuMutex.Unlock()
+	if heat := m.drawHeat(); len(heat.collisions) != 0 || heat.toggles[63][0] != 0 {
+		t.Errorf("after %d frames there are still %d collisions and %d toggles", DRAW_TRACE_FRAMES, len(heat.collisions), heat.toggles[63][0])
+	}
+}
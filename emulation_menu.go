@@ -0,0 +1,137 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"fmt"
+	"github.com/faiface/pixel/pixelgl"
+	"os"
+	"strconv"
+)
+
+// the window's emulation menu, opened with F1.  the machine is paused while it's open, and closing it leaves it
+// paused or running as the menu's pause item says
+type emulationMenu struct {
+	pausingPanel
+	cursor int
+}
+
+const (
+	EMULATION_MENU_PAUSE = iota
+	EMULATION_MENU_RESET
+	EMULATION_MENU_POWER_CYCLE
+	EMULATION_MENU_SPEED
+	EMULATION_MENU_ITEMS
+)
+
+func (menu *emulationMenu) update(win *pixelgl.Window, m *machine) bool {
+	if win.JustPressed(pixelgl.KeyF1) || (menu.open && win.JustPressed(pixelgl.KeyEscape)) {
+		menu.toggle(m)
+		return true
+	}
+	if !menu.open {
+		return false
+	}
+
+	switch {
+	case win.JustPressed(pixelgl.KeyUp) || win.Repeated(pixelgl.KeyUp):
+		menu.cursor--
+	case win.JustPressed(pixelgl.KeyDown) || win.Repeated(pixelgl.KeyDown):
+		menu.cursor++
+	case menu.cursor == EMULATION_MENU_SPEED && (win.JustPressed(pixelgl.KeyLeft) || win.Repeated(pixelgl.KeyLeft)):
+		m.changeSpeed(-1)
+	case menu.cursor == EMULATION_MENU_SPEED && (win.JustPressed(pixelgl.KeyRight) || win.Repeated(pixelgl.KeyRight)):
+		m.changeSpeed(1)
+	case win.JustPressed(pixelgl.KeyEnter) || win.JustPressed(pixelgl.KeySpace):
+		switch menu.cursor {
+		case EMULATION_MENU_PAUSE:
+			menu.wasPaused = !menu.wasPaused
+		case EMULATION_MENU_RESET:
+			m.restart()
+			menu.toggle(m)
+		case EMULATION_MENU_POWER_CYCLE:
+			if err := m.powerCycle(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			menu.toggle(m)
+		case EMULATION_MENU_SPEED:
+			m.changeSpeed(1)
+		}
+	}
+	menu.cursor = clampCursor(menu.cursor, EMULATION_MENU_ITEMS)
+	return true
+}
+
+func (menu *emulationMenu) draw(win *pixelgl.Window, m *machine) {
+	speed, _ := m.speedState()
+	paused := " "
+	if menu.wasPaused {
+		paused = "x"
+	}
+	items := [EMULATION_MENU_ITEMS]string{
+		fmt.Sprintf("[%s] paused", paused),
+		"reset",
+		"power cycle",
+		fmt.Sprintf("speed: < %s >", formatSpeed(speed)),
+	}
+	lines := []string{"emulation | up/down to move, enter to choose, left/right for speed, F1 to close", ""}
+	for idx, item := range items {
+		cursor := " "
+		if idx == menu.cursor {
+			cursor = ">"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s", cursor, item))
+	}
+	lines = append(lines, "",
+		"P pause, N advance a frame while paused, R reset, shift+R power cycle,",
+		"hold space to fast-forward, - and = to change speed")
+	drawPanel(win, lines)
+}
+
+// the emulation hotkeys that work while the game is running, returning a notice to show, if any
+func emulationHotkeys(win *pixelgl.Window, m *machine) string {
+	shift := win.Pressed(pixelgl.KeyLeftShift) || win.Pressed(pixelgl.KeyRightShift)
+	m.setFastForward(win.Pressed(pixelgl.KeySpace))
+	switch {
+	case win.JustPressed(pixelgl.KeyP) || win.JustPressed(pixelgl.KeyPause):
+		m.setPaused(!m.isPaused())
+	case win.JustPressed(pixelgl.KeyN) || win.Repeated(pixelgl.KeyN):
+		if m.isPaused() {
+			m.runFrames(1)
+		}
+	case win.JustPressed(pixelgl.KeyR) && shift:
+		if err := m.powerCycle(); err != nil {
+			return err.Error()
+		}
+		return "power cycled"
+	case win.JustPressed(pixelgl.KeyR):
+		m.restart()
+		return "reset"
+	case win.JustPressed(pixelgl.KeyMinus) || win.Repeated(pixelgl.KeyMinus):
+		return "speed: " + formatSpeed(m.changeSpeed(-1))
+	case win.JustPressed(pixelgl.KeyEqual) || win.Repeated(pixelgl.KeyEqual):
+		return "speed: " + formatSpeed(m.changeSpeed(1))
+	}
+	return ""
+}
+
+// the machine's state for the window title: paused, the speed and fast-forward, or nothing when it's running normally
+func emulationStatus(m *machine) string {
+	var status string
+	if m.isPaused() {
+		status += " | paused"
+	}
+	speed, fastForward := m.speedState()
+	if speed != 1 {
+		status += " | " + formatSpeed(speed)
+	}
+	if fastForward {
+		status += " | fast-forward"
+	}
+	return status
+}
+
+func formatSpeed(speed float64) string {
+	return strconv.FormatFloat(speed, 'g', -1, 64) + "x"
+}
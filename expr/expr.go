@@ -0,0 +1,347 @@
+// Package expr compiles the memory expressions gopotato's achievements and reinforcement learning environments
+// are written in, and evaluates them against a CHIP-8 machine
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Expr is a compiled memory expression, evaluated against a machine, which mustn't be running while it's read.
+// expressions are written like C, over integers:
+//
+//	mem[0x2F0]            the byte at an address
+//	word(0x2F0)           the big-endian 16 bit word at an address
+//	bcd(0x2F0, 3)         digits stored one per byte, as Fx33 writes them, read as a decimal number.  the number
+//	                      of digits is written out, from 1 to MAX_BCD_DIGITS
+//	v0 .. vf, i, pc, sp, dt, st
+//
+// with the operators || && | ^ & == != < <= > >= << >> + - * / % and unary - ! ~.
+// comparisons and logic give 1 or 0; reads outside memory and division by zero give 0
+type Expr func(m Machine) int
+
+// Machine is what expressions read
+type Machine interface {
+	// Peek returns the byte at the address, or 0 outside memory
+	Peek(addr int) int
+	// Register returns the register's value
+	Register(r Register) int
+}
+
+// Register names a CHIP-8 register
+type Register int
+
+// V0 to VF are V0+n for register n
+const (
+	V0 Register = iota
+	I  Register = iota + 15
+	PC
+	SP
+	DT
+	ST
+)
+
+// MAX_BCD_DIGITS is the most digits bcd reads, as many decimal digits as fit in an int
+const MAX_BCD_DIGITS = 18
+
+// Parse compiles an expression
+func Parse(src string) (Expr, error) {
+	p := &exprParser{src: src}
+	p.next()
+	e, err := p.binary(0)
+	if err != nil {
+		return nil, fmt.Errorf("malformed expression %q: %v", src, err)
+	}
+	if p.tok != "" {
+		return nil, fmt.Errorf("malformed expression %q: unexpected %q", src, p.tok)
+	}
+	return e, nil
+}
+
+// the binary operators from loosest to tightest binding
+var exprPrecedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// longest first, so "<=" isn't read as "<"
+var exprSymbols = []string{"||", "&&", "==", "!=", "<=", ">=", "<<", ">>", "|", "^", "&", "<", ">", "+", "-", "*", "/", "%", "!", "~", "(", ")", "[", "]", ","}
+
+type exprParser struct {
+	src string
+	pos int
+	tok string // the current token, or "" at the end of the input
+}
+
+func (p *exprParser) next() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+	if p.pos >= len(p.src) {
+		p.tok = ""
+		return
+	}
+	for _, sym := range exprSymbols {
+		if strings.HasPrefix(p.src[p.pos:], sym) {
+			p.tok = sym
+			p.pos += len(sym)
+			return
+		}
+	}
+	start := p.pos
+	for p.pos < len(p.src) && isExprWordChar(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		p.pos++ // an unknown character, which the parser will reject
+	}
+	p.tok = p.src[start:p.pos]
+}
+
+func isExprWordChar(ch byte) bool {
+	return ch == '_' || ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z'
+}
+
+func (p *exprParser) expect(tok string) error {
+	if p.tok != tok {
+		return fmt.Errorf("expected %q, got %q", tok, p.tok)
+	}
+	p.next()
+	return nil
+}
+
+// parses operators binding at least as tightly as the given level
+func (p *exprParser) binary(level int) (Expr, error) {
+	if level == len(exprPrecedence) {
+		return p.unary()
+	}
+	lhs, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, candidate := range exprPrecedence[level] {
+			if p.tok == candidate {
+				op = candidate
+			}
+		}
+		if op == "" {
+			return lhs, nil
+		}
+		p.next()
+		rhs, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		lhs = binaryExpr(op, lhs, rhs)
+	}
+}
+
+func binaryExpr(op string, lhs, rhs Expr) Expr {
+	switch op {
+	case "||":
+		return func(m Machine) int { return boolToInt(lhs(m) != 0 || rhs(m) != 0) }
+	case "&&":
+		return func(m Machine) int { return boolToInt(lhs(m) != 0 && rhs(m) != 0) }
+	case "|":
+		return func(m Machine) int { return lhs(m) | rhs(m) }
+	case "^":
+		return func(m Machine) int { return lhs(m) ^ rhs(m) }
+	case "&":
+		return func(m Machine) int { return lhs(m) & rhs(m) }
+	case "==":
+		return func(m Machine) int { return boolToInt(lhs(m) == rhs(m)) }
+	case "!=":
+		return func(m Machine) int { return boolToInt(lhs(m) != rhs(m)) }
+	case "<":
+		return func(m Machine) int { return boolToInt(lhs(m) < rhs(m)) }
+	case "<=":
+		return func(m Machine) int { return boolToInt(lhs(m) <= rhs(m)) }
+	case ">":
+		return func(m Machine) int { return boolToInt(lhs(m) > rhs(m)) }
+	case ">=":
+		return func(m Machine) int { return boolToInt(lhs(m) >= rhs(m)) }
+	case "<<":
+		return func(m Machine) int { return lhs(m) << uint(rhs(m)&63) }
+	case ">>":
+		return func(m Machine) int { return lhs(m) >> uint(rhs(m)&63) }
+	case "+":
+		return func(m Machine) int { return lhs(m) + rhs(m) }
+	case "-":
+		return func(m Machine) int { return lhs(m) - rhs(m) }
+	case "*":
+		return func(m Machine) int { return lhs(m) * rhs(m) }
+	case "/":
+		return func(m Machine) int {
+			if d := rhs(m); d != 0 {
+				return lhs(m) / d
+			}
+			return 0
+		}
+	case "%":
+		return func(m Machine) int {
+			if d := rhs(m); d != 0 {
+				return lhs(m) % d
+			}
+			return 0
+		}
+	}
+	panic("unknown operator " + op)
+}
+
+func (p *exprParser) unary() (Expr, error) {
+	switch op := p.tok; op {
+	case "-", "!", "~":
+		p.next()
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "-":
+			return func(m Machine) int { return -operand(m) }, nil
+		case "!":
+			return func(m Machine) int { return boolToInt(operand(m) == 0) }, nil
+		default:
+			return func(m Machine) int { return ^operand(m) }, nil
+		}
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (Expr, error) {
+	tok := p.tok
+	switch {
+	case tok == "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case tok == "(":
+		p.next()
+		e, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	case tok[0] >= '0' && tok[0] <= '9':
+		n, err := strconv.ParseInt(tok, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed number %q", tok)
+		}
+		p.next()
+		return func(m Machine) int { return int(n) }, nil
+	case tok == "mem":
+		p.next()
+		if err := p.expect("["); err != nil {
+			return nil, err
+		}
+		addr, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		return func(m Machine) int { return m.Peek(addr(m)) }, p.expect("]")
+	case tok == "word":
+		p.next()
+		args, err := p.args()
+		if err != nil {
+			return nil, err
+		}
+		if len(args) != 1 {
+			return nil, fmt.Errorf("word takes an address")
+		}
+		return func(m Machine) int {
+			addr := args[0](m)
+			return m.Peek(addr)<<8 | m.Peek(addr+1)
+		}, nil
+	case tok == "bcd":
+		p.next()
+		return p.bcd()
+	}
+
+	p.next()
+	switch tok {
+	case "i":
+		return register(I), nil
+	case "pc":
+		return register(PC), nil
+	case "sp":
+		return register(SP), nil
+	case "dt":
+		return register(DT), nil
+	case "st":
+		return register(ST), nil
+	}
+	if len(tok) == 2 && tok[0] == 'v' {
+		if n, err := strconv.ParseUint(tok[1:], 16, 4); err == nil {
+			return register(V0 + Register(n)), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown name %q", tok)
+}
+
+// the arguments of bcd: an address, and a number of digits written out, so it can be checked here rather than
+// looping for as long as it says on every evaluation
+func (p *exprParser) bcd() (Expr, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	addr, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.tok != "," {
+		return nil, fmt.Errorf("bcd takes an address and a number of digits")
+	}
+	p.next()
+	digits, err := strconv.ParseInt(p.tok, 0, 64)
+	if err != nil || digits < 1 || digits > MAX_BCD_DIGITS {
+		return nil, fmt.Errorf("bcd reads from 1 to %d digits, not %q", MAX_BCD_DIGITS, p.tok)
+	}
+	p.next()
+	return func(m Machine) int {
+		base, n := addr(m), 0
+		for idx := 0; idx < int(digits); idx++ {
+			n = n*10 + m.Peek(base+idx)
+		}
+		return n
+	}, p.expect(")")
+}
+
+// a parenthesised, comma separated argument list
+func (p *exprParser) args() ([]Expr, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var args []Expr
+	for p.tok != ")" {
+		arg, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.tok != "," {
+			break
+		}
+		p.next()
+	}
+	return args, p.expect(")")
+}
+
+func register(r Register) Expr {
+	return func(m Machine) int { return m.Register(r) }
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
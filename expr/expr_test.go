@@ -0,0 +1,126 @@
+package expr
+
+import (
+	"strings"
+	"testing"
+)
+
+// fakeMachine has memory and registers to read, and nothing else
+type fakeMachine struct {
+	mem  [4096]byte
+	regs map[Register]int
+}
+
+func (f *fakeMachine) Peek(addr int) int {
+	if addr < 0 || addr >= len(f.mem) {
+		return 0
+	}
+	return int(f.mem[addr])
+}
+
+func (f *fakeMachine) Register(r Register) int {
+	return f.regs[r]
+}
+
+func newFakeMachine() *fakeMachine {
+	f := &fakeMachine{regs: map[Register]int{V0: 7, V0 + 0xA: 0x20, I: 0x300, PC: 0x202, SP: 1, DT: 30, ST: 2}}
+	copy(f.mem[0x2F0:], []byte{1, 2, 3, 0, 9})
+	f.mem[0x300] = 0xAB
+	f.mem[0x301] = 0xCD
+	return f
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		src  string
+		want int
+	}{
+		// precedence, loosest to tightest
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"100 / 10 / 5", 2},
+		{"1 << 2 + 1", 8},
+		{"1 + 1 < 3", 1},
+		{"2 < 3 == 1", 1},
+		{"6 & 3 == 2", 0},
+		{"1 | 2 ^ 3 & 5", 3},
+		{"0 || 1 && 0", 0},
+		{"1 || 0 && 0", 1},
+		{"7 % 4 * 2", 6},
+		// unary operators, which bind tighter than any binary one
+		{"-3 + 5", 2},
+		{"- -3", 3},
+		{"!0", 1},
+		{"!5", 0},
+		{"~0", -1},
+		{"!0 + 1", 2},
+		{"-mem[0x2F0] * 2", -2},
+		// division by zero gives 0
+		{"5 / 0", 0},
+		{"5 % 0", 0},
+		// memory, registers and the helpers
+		{"mem[0x2F2]", 3},
+		{"mem[0x2F0 + 4]", 9},
+		{"mem[5000]", 0},
+		{"word(0x300)", 0xABCD},
+		{"word(i)", 0xABCD},
+		{"bcd(0x2F0, 3)", 123},
+		{"bcd(0x2F0, 5)", 12309},
+		{"bcd(0x2F0, 1)", 1},
+		{"bcd(0x2F0 + 1, 2)", 23},
+		{"v0", 7},
+		{"va", 0x20},
+		{"pc + sp + dt + st", 0x202 + 1 + 30 + 2},
+		{"mem[i + 1]", 0xCD},
+		{"0x10 + 010 + 10", 16 + 8 + 10},
+	}
+	m := newFakeMachine()
+	for _, test := range tests {
+		e, err := Parse(test.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.src, err)
+			continue
+		}
+		if got := e(m); got != test.want {
+			t.Errorf("%q = %d, want %d", test.src, got, test.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"", "unexpected end"},
+		{"1 +", "unexpected end"},
+		{"(1 + 2", `expected ")"`},
+		{"mem[1", `expected "]"`},
+		{"mem 1", `expected "["`},
+		{"1 2", `unexpected "2"`},
+		{"foo", `unknown name "foo"`},
+		{"vg", `unknown name "vg"`},
+		{"VA", `unknown name "VA"`},
+		{"1 $ 2", `unexpected "$"`},
+		{"0x", "malformed number"},
+		{"word()", "word takes an address"},
+		{"word(1, 2)", "word takes an address"},
+		{"bcd(0x2F0)", "bcd takes an address and a number of digits"},
+		{"bcd(0x2F0, 0)", "bcd reads from 1 to 18 digits"},
+		{"bcd(0x2F0, 19)", "bcd reads from 1 to 18 digits"},
+		{"bcd(0x2F0, 1e9)", "bcd reads from 1 to 18 digits"},
+		{"bcd(0x2F0, 1000000000)", "bcd reads from 1 to 18 digits"},
+		{"bcd(0x2F0, v0)", "bcd reads from 1 to 18 digits"},
+		{"bcd(0x2F0, 3, 4)", `expected ")"`},
+	}
+	for _, test := range tests {
+		_, err := Parse(test.src)
+		if err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error containing %q", test.src, test.want)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Parse(%q) = %q, want an error containing %q", test.src, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	imgpalette "image/color/palette"
+	"image/draw"
+	"strings"
+	"sync"
+)
+
+// filter post-processes the display's image before it's shown or saved, scaling it up by its factor
+type filter struct {
+	name   string
+	factor int
+	apply  func(src *image.RGBA) *image.RGBA // nil leaves the image as it is
+}
+
+// the built-in filters, in the order the hotkey cycles through them
+var filters = []filter{
+	{"none", 1, nil},
+	{"scale2x", 2, scale2x},
+	{"scale3x", 3, scale3x},
+	{"hq", 4, hqSmooth},
+	{"scanlines", 3, scanlines},
+	{"grid", 4, pixelGrid},
+}
+
+// the filter every image frontend applies
+var activeFilter = struct {
+	sync.Mutex
+	filter
+}{filter: filters[0]}
+
+func currentFilter() filter {
+	activeFilter.Lock()
+	defer activeFilter.Unlock()
+	return activeFilter.filter
+}
+
+// switches to the named filter
+func setFilter(name string) error {
+	for _, f := range filters {
+		if strings.EqualFold(f.name, name) {
+			activeFilter.Lock()
+			defer activeFilter.Unlock()
+			activeFilter.filter = f
+			return nil
+		}
+	}
+	names := make([]string, len(filters))
+	for idx, f := range filters {
+		names[idx] = f.name
+	}
+	return fmt.Errorf("unknown filter %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// switches to the filter after the active one, returning it
+func cycleFilter() filter {
+	activeFilter.Lock()
+	defer activeFilter.Unlock()
+	for idx, f := range filters {
+		if f.name == activeFilter.name {
+			activeFilter.filter = filters[(idx+1)%len(filters)]
+			break
+		}
+	}
+	return activeFilter.filter
+}
+
+// the shades as a 1x image in the palette's background and foreground
+func (s shades) image(p palette) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, XRES, YRES))
+	for x := range s {
+		for y := range s[x] {
+			img.SetRGBA(x, y, s.color(p, x, y))
+		}
+	}
+	return img
+}
+
+// the image through the filter, at the filter's factor
+func (f filter) run(src *image.RGBA) *image.RGBA {
+	if f.apply == nil {
+		return src
+	}
+	return f.apply(src)
+}
+
+// the framebuffer in the active palette through the filter, scaled up by as near to scale as the filter's factor allows
+func (fb framebuffer) filtered(f filter, scale int) *image.Paletted {
+	var s shades
+	for x := range fb {
+		for y, lit := range fb[x] {
+			if lit {
+				s[x][y] = 1
+			}
+		}
+	}
+	k := (scale + f.factor/2) / f.factor
+	if k < 1 {
+		k = 1
+	}
+	return toPaletted(nearest(f.run(s.image(currentPalette())), k))
+}
+
+// the image with its own colours as the palette, for GIFs.  images with too many colours fall back to a generic palette
+func toPaletted(src *image.RGBA) *image.Paletted {
+	var colors color.Palette
+	seen := map[color.RGBA]bool{}
+	for idx := 0; idx < len(src.Pix); idx += 4 {
+		c := color.RGBA{src.Pix[idx], src.Pix[idx+1], src.Pix[idx+2], src.Pix[idx+3]}
+		if !seen[c] {
+			seen[c] = true
+			colors = append(colors, c)
+		}
+	}
+	if len(colors) > 256 {
+		colors = imgpalette.Plan9
+	}
+	dst := image.NewPaletted(src.Bounds(), colors)
+	draw.Draw(dst, dst.Bounds(), src, image.Point{}, draw.Src)
+	return dst
+}
+
+// each pixel as a k by k square
+func nearest(src *image.RGBA, k int) *image.RGBA {
+	if k == 1 {
+		return src
+	}
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx()*k, b.Dy()*k))
+	for y := 0; y < b.Dy()*k; y++ {
+		for x := 0; x < b.Dx()*k; x++ {
+			dst.SetRGBA(x, y, src.RGBAAt(x/k, y/k))
+		}
+	}
+	return dst
+}
+
+// the pixel at x, y, with coordinates off the edge clamped to it
+func clampedAt(img *image.RGBA, x, y int) color.RGBA {
+	b := img.Bounds()
+	if x < b.Min.X {
+		x = b.Min.X
+	} else if x >= b.Max.X {
+		x = b.Max.X - 1
+	}
+	if y < b.Min.Y {
+		y = b.Min.Y
+	} else if y >= b.Max.Y {
+		y = b.Max.Y - 1
+	}
+	return img.RGBAAt(x, y)
+}
+
+// EPX, doubling each pixel and rounding off the corners of diagonal edges
+func scale2x(src *image.RGBA) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx()*2, b.Dy()*2))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			p := src.RGBAAt(x, y)
+			up, down := clampedAt(src, x, y-1), clampedAt(src, x, y+1)
+			left, right := clampedAt(src, x-1, y), clampedAt(src, x+1, y)
+			out := [4]color.RGBA{p, p, p, p}
+			if up != down && left != right {
+				if left == up {
+					out[0] = up
+				}
+				if up == right {
+					out[1] = right
+				}
+				if left == down {
+					out[2] = left
+				}
+				if down == right {
+					out[3] = down
+				}
+			}
+			dst.SetRGBA(2*x, 2*y, out[0])
+			dst.SetRGBA(2*x+1, 2*y, out[1])
+			dst.SetRGBA(2*x, 2*y+1, out[2])
+			dst.SetRGBA(2*x+1, 2*y+1, out[3])
+		}
+	}
+	return dst
+}
+
+// Scale2x's rules extended to tripling each pixel
+func scale3x(src *image.RGBA) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx()*3, b.Dy()*3))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			// the neighbourhood, read left to right and top to bottom
+			a, bb, c := clampedAt(src, x-1, y-1), clampedAt(src, x, y-1), clampedAt(src, x+1, y-1)
+			d, e, f := clampedAt(src, x-1, y), src.RGBAAt(x, y), clampedAt(src, x+1, y)
+			g, h, i := clampedAt(src, x-1, y+1), clampedAt(src, x, y+1), clampedAt(src, x+1, y+1)
+			out := [9]color.RGBA{e, e, e, e, e, e, e, e, e}
+			if bb != h && d != f {
+				pick := func(cond bool, c color.RGBA) color.RGBA {
+					if cond {
+						return c
+					}
+					return e
+				}
+				out[0] = pick(d == bb, d)
+				out[1] = pick((d == bb && e != c) || (bb == f && e != a), bb)
+				out[2] = pick(bb == f, f)
+				out[3] = pick((d == bb && e != g) || (d == h && e != a), d)
+				out[5] = pick((bb == f && e != i) || (h == f && e != c), f)
+				out[6] = pick(d == h, d)
+				out[7] = pick((d == h && e != i) || (h == f && e != g), h)
+				out[8] = pick(h == f, f)
+			}
+			for idx, col := range out {
+				dst.SetRGBA(3*x+idx%3, 3*y+idx/3, col)
+			}
+		}
+	}
+	return dst
+}
+
+// a cheap take on hqx: Scale2x twice, then edges softened by blending each pixel with its neighbours
+func hqSmooth(src *image.RGBA) *image.RGBA {
+	big := scale2x(scale2x(src))
+	b := big.Bounds()
+	dst := image.NewRGBA(b)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			// the pixel counts four times, each of its neighbours once
+			var sum [4]int
+			add := func(c color.RGBA, weight int) {
+				sum[0] += int(c.R) * weight
+				sum[1] += int(c.G) * weight
+				sum[2] += int(c.B) * weight
+				sum[3] += int(c.A) * weight
+			}
+			add(big.RGBAAt(x, y), 4)
+			add(clampedAt(big, x-1, y), 1)
+			add(clampedAt(big, x+1, y), 1)
+			add(clampedAt(big, x, y-1), 1)
+			add(clampedAt(big, x, y+1), 1)
+			dst.SetRGBA(x, y, color.RGBA{byte(sum[0] / 8), byte(sum[1] / 8), byte(sum[2] / 8), byte(sum[3] / 8)})
+		}
+	}
+	return dst
+}
+
+// each pixel tripled, with the bottom row of every three dimmed like the gaps between a CRT's lines
+func scanlines(src *image.RGBA) *image.RGBA {
+	dst := nearest(src, 3)
+	b := dst.Bounds()
+	for y := 2; y < b.Dy(); y += 3 {
+		for x := 0; x < b.Dx(); x++ {
+			dst.SetRGBA(x, y, dim(dst.RGBAAt(x, y), 0.5))
+		}
+	}
+	return dst
+}
+
+// each pixel quadrupled, with a dimmed line along its right and bottom edges like an LCD's grid
+func pixelGrid(src *image.RGBA) *image.RGBA {
+	dst := nearest(src, 4)
+	b := dst.Bounds()
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			if x%4 == 3 || y%4 == 3 {
+				dst.SetRGBA(x, y, dim(dst.RGBAAt(x, y), 0.75))
+			}
+		}
+	}
+	return dst
+}
+
+// the colour with its brightness scaled
+func dim(c color.RGBA, brightness float64) color.RGBA {
+	scale := func(v byte) byte {
+		return byte(float64(v) * brightness)
+	}
+	return color.RGBA{scale(c.R), scale(c.G), scale(c.B), c.A}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// test images are drawn in greys: '.' is black, '#' is white and a digit n is n eighths of the way between
+func picture(rows ...string) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x, ch := range row {
+			var level byte
+			switch {
+			case ch == '#':
+				level = 0xFF
+			case ch >= '0' && ch <= '8':
+				level = byte(0xFF * int(ch-'0') / 8)
+			}
+			img.SetRGBA(x, y, color.RGBA{level, level, level, 0xFF})
+		}
+	}
+	return img
+}
+
+// the inverse of picture, with '?' for any colour it can't draw
+func drawing(img image.Image) []string {
+	b := img.Bounds()
+	var rows []string
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := ""
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			ch := "?"
+			for n := 0; n <= 8; n++ {
+				if level := byte(0xFF * n / 8); c == (color.RGBA{level, level, level, 0xFF}) {
+					ch = string(rune('0' + n))
+				}
+			}
+			switch ch {
+			case "0":
+				ch = "."
+			case "8":
+				ch = "#"
+			}
+			row += ch
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func assertDrawing(t *testing.T, name string, got image.Image, want []string) {
+	t.Helper()
+	if rows := drawing(got); strings.Join(rows, "\n") != strings.Join(want, "\n") {
+		t.Errorf("%s gave\n%s\nwant\n%s", name, strings.Join(rows, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// a diagonal line, which the smoothing filters round off and the others just scale
+var filterInput = []string{
+	"#...",
+	".#..",
+	"..#.",
+	"...#",
+}
+
+func TestFilters(t *testing.T) {
+	golden := map[string][]string{
+		"none": filterInput,
+		"scale2x": {
+			"##......",
+			"#.#.....",
+			".###....",
+			"..###...",
+			"...###..",
+			"....###.",
+			".....#.#",
+			"......##",
+		},
+		"scale3x": {
+			"###.........",
+			"##.#........",
+			"#..#........",
+			".#####......",
+			"...###......",
+			"...####.....",
+			".....####...",
+			"......###...",
+			"......#####.",
+			"........#..#",
+			"........#.##",
+			".........###",
+		},
+		"hq": {
+			"###62...........",
+			"##6352..........",
+			"#621762.........",
+			"63127#61........",
+			"2577##721.......",
+			".26####761......",
+			"..267###721.....",
+			"...127###761....",
+			"....167###721...",
+			".....127###762..",
+			"......167####62.",
+			".......127##7752",
+			"........16#72136",
+			".........267126#",
+			"..........2536##",
+			"...........26###",
+		},
+		"scanlines": {
+			"###.........",
+			"###.........",
+			"444.........",
+			"...###......",
+			"...###......",
+			"...444......",
+			"......###...",
+			"......###...",
+			"......444...",
+			".........###",
+			".........###",
+			".........444",
+		},
+		"grid": {
+			"###6............",
+			"###6............",
+			"###6............",
+			"6666............",
+			"....###6........",
+			"....###6........",
+			"....###6........",
+			"....6666........",
+			"........###6....",
+			"........###6....",
+			"........###6....",
+			"........6666....",
+			"............###6",
+			"............###6",
+			"............###6",
+			"............6666",
+		},
+	}
+	for _, f := range filters {
+		want, ok := golden[f.name]
+		if !ok {
+			t.Errorf("no golden image for the %s filter", f.name)
+			continue
+		}
+		got := f.run(picture(filterInput...))
+		if size := got.Bounds().Size(); size != image.Pt(4*f.factor, 4*f.factor) {
+			t.Errorf("%s scaled 4x4 to %v, want %dx", f.name, size, f.factor)
+		}
+		assertDrawing(t, f.name, got, want)
+	}
+}
+
+// what screenshots and GIFs are made from: the filter, then whole-pixel scaling to near the asked-for size
+func TestFilteredFramebuffer(t *testing.T) {
+	setTheme(themes[0])
+	var fb framebuffer
+	for n := 0; n < 4; n++ {
+		fb[n][n] = true
+	}
+	scale2x, _ := filterNamed("scale2x")
+	for _, tc := range []struct {
+		f     filter
+		scale int
+		size  image.Point
+		want  []string
+	}{
+		{filters[0], 2, image.Pt(XRES*2, YRES*2), []string{
+			"##......",
+			"##......",
+			"..##....",
+			"..##....",
+		}},
+		// scale2x doubles, so asking for 4x doubles its output again
+		{scale2x, 4, image.Pt(XRES*4, YRES*4), []string{
+			"####............",
+			"####............",
+			"##..##..........",
+			"##..##..........",
+			"..######........",
+			"..######........",
+		}},
+		// a factor larger than the scale isn't shrunk back down
+		{scale2x, 1, image.Pt(XRES*2, YRES*2), []string{
+			"##......",
+			"#.#.....",
+			".###....",
+		}},
+	} {
+		img := fb.filtered(tc.f, tc.scale)
+		if size := img.Bounds().Size(); size != tc.size {
+			t.Errorf("%s at %dx is %v, want %v", tc.f.name, tc.scale, size, tc.size)
+		}
+		if len(img.Palette) != 2 {
+			t.Errorf("%s at %dx has %d colours, want 2", tc.f.name, tc.scale, len(img.Palette))
+		}
+		crop := img.SubImage(image.Rect(0, 0, len(tc.want[0]), len(tc.want)))
+		assertDrawing(t, tc.f.name, crop, tc.want)
+	}
+}
+
+// the built-in filter with the name
+func filterNamed(name string) (filter, bool) {
+	for _, f := range filters {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return filter{}, false
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"sync"
+	"time"
+)
+
+// anti-flicker modes.  CHIP-8 games move sprites by erasing them with an XOR draw and drawing them again,
+// so a straight copy of the framebuffer catches them half drawn.  these only change what's shown, never what's emulated
+const (
+	// shows the framebuffer as it is
+	FLICKER_OFF = "off"
+	// lights a pixel that's lit at this vblank or was at the last one
+	FLICKER_BLEND = "blend"
+	// lights a pixel fully while it's on, fading it out over the fade time once it's off, like a phosphor screen
+	FLICKER_DECAY = "decay"
+	// shows the framebuffer only as it was at the end of each frame
+	FLICKER_VBLANK = "vblank"
+
+	// how long an unlit pixel takes to fade out in decay mode, unless configured
+	DEFAULT_FADE_TIME = 100 * time.Millisecond
+)
+
+var flickerModes = map[string]bool{FLICKER_OFF: true, FLICKER_BLEND: true, FLICKER_DECAY: true, FLICKER_VBLANK: true}
+
+// shades are how brightly each pixel is shown, from 0 for background to 1 for foreground
+type shades [XRES][YRES]float32
+
+// the anti-flicker mode every display is shown in
+var antiFlicker = struct {
+	sync.Mutex
+	mode       string
+	fadeFrames int // how many vblanks a pixel takes to fade out
+}{mode: FLICKER_OFF, fadeFrames: fadeFrames(DEFAULT_FADE_TIME)}
+
+// flickerConfig sets the anti-flicker mode in the config file, e.g. {"mode": "decay", "fade": "150ms"}
+type flickerConfig struct {
+	Mode string `json:"mode"`
+	Fade string `json:"fade"`
+}
+
+// switches every display to the mode.  a blank mode or fade keeps the current one
+func setAntiFlicker(fc flickerConfig) error {
+	antiFlicker.Lock()
+	defer antiFlicker.Unlock()
+	mode := antiFlicker.mode
+	if fc.Mode != "" {
+		if !flickerModes[fc.Mode] {
+			var names []string
+			for name := range flickerModes {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("unknown anti-flicker mode %q, expected one of %v", fc.Mode, names)
+		}
+		mode = fc.Mode
+	}
+	frames := antiFlicker.fadeFrames
+	if fc.Fade != "" {
+		fade, err := time.ParseDuration(fc.Fade)
+		if err != nil || fade < 0 {
+			return fmt.Errorf("malformed fade time %q, expected a duration like 150ms", fc.Fade)
+		}
+		frames = fadeFrames(fade)
+	}
+	antiFlicker.mode, antiFlicker.fadeFrames = mode, frames
+	return nil
+}
+
+// the fade time in 60hz frames, at least one
+func fadeFrames(fade time.Duration) int {
+	frames := int(fade * 60 / time.Second)
+	if frames < 1 {
+		return 1
+	}
+	return frames
+}
+
+func flickerSettings() (string, int) {
+	antiFlicker.Lock()
+	defer antiFlicker.Unlock()
+	return antiFlicker.mode, antiFlicker.fadeFrames
+}
+
+// works out what's shown for the frame that just ended.  called once a frame by the timers
+func (disp *display) vblank() {
+	mode, frames := flickerSettings()
+	disp.Lock()
+	defer disp.Unlock()
+	prev := disp.shade
+	for x := range disp.fb {
+		for y, lit := range disp.fb[x] {
+			var shade float32
+			switch {
+			case lit:
+				shade = 1
+			case mode == FLICKER_BLEND && disp.lastFB[x][y]:
+				shade = 1
+			case mode == FLICKER_DECAY:
+				if shade = disp.shade[x][y] - 1/float32(frames); shade < 0 {
+					shade = 0
+				}
+			}
+			disp.shade[x][y] = shade
+		}
+	}
+	disp.lastFB = disp.fb
+	if disp.shade != prev {
+		disp.updated = true
+	}
+}
+
+// the display as it should be shown, after anti-flicker
+func (disp *display) shades() shades {
+	mode, _ := flickerSettings()
+	disp.Lock()
+	defer disp.Unlock()
+	if mode != FLICKER_OFF {
+		return disp.shade
+	}
+	var s shades
+	for x := range disp.fb {
+		for y, lit := range disp.fb[x] {
+			if lit {
+				s[x][y] = 1
+			}
+		}
+	}
+	return s
+}
+
+// the display as it should be shown, for frontends that can only light a pixel or not.
+// fading pixels stay lit until they're half faded
+func (disp *display) shown() framebuffer {
+	var fb framebuffer
+	s := disp.shades()
+	for x := range s {
+		for y, shade := range s[x] {
+			fb[x][y] = shade >= 0.5
+		}
+	}
+	return fb
+}
+
+// the colour a pixel is shown in, between the background and foreground
+func (s shades) color(p palette, x, y int) color.RGBA {
+	shade := s[x][y]
+	bg, fg := p[0], p[1]
+	mix := func(a, b byte) byte {
+		return byte(float32(a) + (float32(b)-float32(a))*shade + 0.5)
+	}
+	return color.RGBA{mix(bg.R, fg.R), mix(bg.G, fg.G), mix(bg.B, fg.B), 0xFF}
+}
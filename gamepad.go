@@ -0,0 +1,45 @@
+package main
+
+// gamepadBindings maps the names of gamepad controls to the hex key each one holds down
+type gamepadBindings map[string]hexKey
+
+// one set of bindings per gamepad: the first entry is Joystick1, the second Joystick2, etc.
+type gamepadProfile []gamepadBindings
+
+// the COSMAC VIP keypad puts 2/4/6/8 around 5, which most games use as a d-pad
+var defaultGamepadProfile = gamepadProfile{
+	{
+		"dpad-up":          0x02,
+		"dpad-left":        0x04,
+		"dpad-right":       0x06,
+		"dpad-down":        0x08,
+		"left-stick-up":    0x02,
+		"left-stick-left":  0x04,
+		"left-stick-right": 0x06,
+		"left-stick-down":  0x08,
+		"a":                0x05,
+		"b":                0x00,
+		"x":                0x07,
+		"y":                0x09,
+		"left-bumper":      0x01,
+		"right-bumper":     0x03,
+		"back":             0x0A,
+		"start":            0x0B,
+	},
+}
+
+// Pong moves the left paddle with 1/4 and the right paddle with C/D
+var pongGamepadProfile = gamepadProfile{
+	{
+		"dpad-up":         0x01,
+		"dpad-down":       0x04,
+		"left-stick-up":   0x01,
+		"left-stick-down": 0x04,
+	},
+	{
+		"dpad-up":         0x0C,
+		"dpad-down":       0x0D,
+		"left-stick-up":   0x0C,
+		"left-stick-down": 0x0D,
+	},
+}
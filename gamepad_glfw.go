@@ -0,0 +1,101 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"fmt"
+	"github.com/faiface/pixel/pixelgl"
+)
+
+// how far a stick or trigger must travel before it counts as held
+const GAMEPAD_AXIS_THRESHOLD = 0.5
+
+var gamepadButtons = map[string]pixelgl.GamepadButton{
+	"a":            pixelgl.ButtonA,
+	"b":            pixelgl.ButtonB,
+	"x":            pixelgl.ButtonX,
+	"y":            pixelgl.ButtonY,
+	"left-bumper":  pixelgl.ButtonLeftBumper,
+	"right-bumper": pixelgl.ButtonRightBumper,
+	"back":         pixelgl.ButtonBack,
+	"start":        pixelgl.ButtonStart,
+	"guide":        pixelgl.ButtonGuide,
+	"left-thumb":   pixelgl.ButtonLeftThumb,
+	"right-thumb":  pixelgl.ButtonRightThumb,
+	"dpad-up":      pixelgl.ButtonDpadUp,
+	"dpad-right":   pixelgl.ButtonDpadRight,
+	"dpad-down":    pixelgl.ButtonDpadDown,
+	"dpad-left":    pixelgl.ButtonDpadLeft,
+}
+
+// an axis held past the threshold in one direction
+type gamepadAxis struct {
+	axis pixelgl.GamepadAxis
+	sign float64
+}
+
+var gamepadAxes = map[string]gamepadAxis{
+	// GLFW's y axes point down
+	"left-stick-up":     {pixelgl.AxisLeftY, -1},
+	"left-stick-down":   {pixelgl.AxisLeftY, 1},
+	"left-stick-left":   {pixelgl.AxisLeftX, -1},
+	"left-stick-right":  {pixelgl.AxisLeftX, 1},
+	"right-stick-up":    {pixelgl.AxisRightY, -1},
+	"right-stick-down":  {pixelgl.AxisRightY, 1},
+	"right-stick-left":  {pixelgl.AxisRightX, -1},
+	"right-stick-right": {pixelgl.AxisRightX, 1},
+	// triggers rest at -1 and travel to 1
+	"left-trigger":  {pixelgl.AxisLeftTrigger, 1},
+	"right-trigger": {pixelgl.AxisRightTrigger, 1},
+}
+
+// checks that every control named in the profile exists
+func (p gamepadProfile) validate() error {
+	for padIdx, bindings := range p {
+		for control := range bindings {
+			_, isButton := gamepadButtons[control]
+			_, isAxis := gamepadAxes[control]
+			if !isButton && !isAxis {
+				return fmt.Errorf("unknown control %q bound on gamepad %d", control, padIdx+1)
+			}
+		}
+	}
+	return nil
+}
+
+// gamepad reads the hex keypad from a GLFW joystick through a binding table
+type gamepad struct {
+	win      *pixelgl.Window
+	js       pixelgl.Joystick
+	bindings gamepadBindings
+}
+
+// creates one gamepad input source per binding set in the profile
+func gamepadsFor(win *pixelgl.Window, profile gamepadProfile) []inputSource {
+	var pads []inputSource
+	for padIdx, bindings := range profile {
+		js := pixelgl.Joystick1 + pixelgl.Joystick(padIdx)
+		if js > pixelgl.JoystickLast {
+			break
+		}
+		pads = append(pads, gamepad{win: win, js: js, bindings: bindings})
+	}
+	return pads
+}
+
+func (g gamepad) heldKeys() [16]bool {
+	var held [16]bool
+	if !g.win.JoystickPresent(g.js) {
+		return held
+	}
+	for control, key := range g.bindings {
+		if button, ok := gamepadButtons[control]; ok && g.win.JoystickPressed(g.js, button) {
+			held[key] = true
+		}
+		if ax, ok := gamepadAxes[control]; ok && g.win.JoystickAxis(g.js, ax.axis)*ax.sign > GAMEPAD_AXIS_THRESHOLD {
+			held[key] = true
+		}
+	}
+	return held
+}
@@ -0,0 +1,130 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"github.com/raidancampbell/gopotato/gym"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// runs a ROM as an RL environment driven over stdin and stdout, one command per line:
+//
+//	reset           -> obs 0 0 <observation>
+//	step <action>   -> obs <reward> <done> <observation>
+//	quit
+//
+// actions are keypad bitmasks in decimal or 0x hex, done is 0 or 1, and observations are the
+// framebuffer packed row by row, most significant bit first, in hex.  errors are reported as "error <message>"
+func gymCommand(args []string) error {
+	fs := flag.NewFlagSet("gym", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gopotato gym [flags] rom.ch8\n")
+		fs.PrintDefaults()
+	}
+	score := fs.String("score", "", "score expression, overriding the config file")
+	done := fs.String("done", "", "episode over expression, overriding the config file")
+	frameSkip := fs.Int("frame-skip", 0, "frames each action is held for, overriding the config file")
+	maxFrames := fs.Int("max-frames", 0, "frames before an episode is cut off, overriding the config file")
+	machineFlags(fs)
+	fs.Parse(args)
+	m, err := loadGame(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	m.achievements = nil // agents don't earn them
+
+	cfg := romCfg.Env
+	if *score != "" {
+		cfg.Score = *score
+	}
+	if *done != "" {
+		cfg.Done = *done
+	}
+	if *frameSkip > 0 {
+		cfg.FrameSkip = *frameSkip
+	}
+	if *maxFrames > 0 {
+		cfg.MaxFrames = *maxFrames
+	}
+	e, err := gym.New(newGymMachine(m), cfg)
+	if err != nil {
+		return err
+	}
+	defer finishRecording(m)
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	fmt.Fprintf(out, "ready %d %d %d\n", XRES, YRES, e.FrameSkip())
+	out.Flush()
+	in := bufio.NewScanner(os.Stdin)
+	for in.Scan() {
+		fields := strings.Fields(in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "reset":
+			fmt.Fprintf(out, "obs 0 0 %s\n", hex.EncodeToString(framebuffer(e.Reset()).pack()))
+		case "step":
+			if len(fields) != 2 {
+				fmt.Fprintln(out, "error step takes one action")
+				break
+			}
+			action, err := strconv.ParseUint(fields[1], 0, 16)
+			if err != nil {
+				fmt.Fprintf(out, "error malformed action %q\n", fields[1])
+				break
+			}
+			obs, reward, over := e.Step(uint16(action))
+			ended := 0
+			if over {
+				ended = 1
+			}
+			fmt.Fprintf(out, "obs %d %d %s\n", reward, ended, hex.EncodeToString(framebuffer(obs).pack()))
+		case "quit":
+			return nil
+		default:
+			fmt.Fprintf(out, "error unknown command %q\n", fields[0])
+		}
+		out.Flush()
+	}
+	return in.Err()
+}
+
+// gymMachine lets a gym.Env play a machine, which must not be started so that nothing else runs it
+type gymMachine struct {
+	*machine
+	input *virtualKeypad
+}
+
+func newGymMachine(m *machine) *gymMachine {
+	g := &gymMachine{machine: m, input: &virtualKeypad{}}
+	m.inputSources = []inputSource{g.input}
+	return g
+}
+
+func (g *gymMachine) Restart() {
+	g.restart()
+}
+
+func (g *gymMachine) SetKeys(keys uint16) {
+	for nibble := byte(0); nibble <= 0x0F; nibble++ {
+		g.input.set(nibble, keys&(1<<nibble) != 0)
+	}
+	g.pollForKeys()
+}
+
+func (g *gymMachine) RunFrames(n int) {
+	g.runFrames(n)
+}
+
+func (g *gymMachine) Screen() gym.Observation {
+	return gym.Observation(g.disp.snapshot())
+}
@@ -0,0 +1,106 @@
+// Package gym plays CHIP-8 ROMs as gym-like reinforcement learning environments, scored by memory expressions
+package gym
+
+import "github.com/raidancampbell/gopotato/expr"
+
+// the CHIP-8 display's size, which observations are
+const (
+	WIDTH  = 64
+	HEIGHT = 32
+)
+
+// Observation is the display, indexed by x then y, with true for a lit pixel
+type Observation [WIDTH][HEIGHT]bool
+
+// Config is how a ROM is played as an environment, written in gopotato's config file as memory expressions
+type Config struct {
+	Score     string `json:"score"`     // the reward for a step is how much this rose during it
+	Done      string `json:"done"`      // the episode is over once this is nonzero
+	FrameSkip int    `json:"frameSkip"` // frames each action is held for, 4 if unset
+	MaxFrames int    `json:"maxFrames"` // ends episodes after this many frames, if set
+}
+
+// Emulator is the machine an Env plays.  it never runs by itself: it only advances in RunFrames, so expressions
+// can read it between steps
+type Emulator interface {
+	expr.Machine
+	// Restart starts the ROM again from the top
+	Restart()
+	// SetKeys holds the keypad keys set in the mask, bit n for key n, and releases the rest
+	SetKeys(keys uint16)
+	// RunFrames emulates n frames as fast as it can
+	RunFrames(n int)
+	// Screen returns what's on the display
+	Screen() Observation
+}
+
+// Env is a gym-like environment around an Emulator, advancing only in Step, as fast as the host can emulate.
+// observations are the display and actions are keypad bitmasks, bit n holding key n
+type Env struct {
+	m         Emulator
+	score     expr.Expr
+	done      expr.Expr
+	frameSkip int
+	maxFrames int
+
+	frames    int
+	lastScore int
+}
+
+// New wraps an emulator with its ROM loaded
+func New(m Emulator, cfg Config) (*Env, error) {
+	e := &Env{
+		m:         m,
+		frameSkip: cfg.FrameSkip,
+		maxFrames: cfg.MaxFrames,
+	}
+	if e.frameSkip <= 0 {
+		e.frameSkip = 4
+	}
+	var err error
+	if e.score, err = parseExpr(cfg.Score); err != nil {
+		return nil, err
+	}
+	if e.done, err = parseExpr(cfg.Done); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// an unset expression is always 0
+func parseExpr(src string) (expr.Expr, error) {
+	if src == "" {
+		return func(m expr.Machine) int { return 0 }, nil
+	}
+	return expr.Parse(src)
+}
+
+// FrameSkip returns the frames each action is held for
+func (e *Env) FrameSkip() int {
+	return e.frameSkip
+}
+
+// Reset restarts the ROM with no keys held and returns the first observation
+func (e *Env) Reset() Observation {
+	e.m.SetKeys(0)
+	e.m.Restart()
+	e.frames = 0
+	e.lastScore = e.score(e.m)
+	return e.m.Screen()
+}
+
+// Step holds the keys in the action for FrameSkip frames, returning the observation, the reward and whether the episode is over
+func (e *Env) Step(action uint16) (Observation, int, bool) {
+	e.m.SetKeys(action)
+	e.m.RunFrames(e.frameSkip)
+	e.frames += e.frameSkip
+
+	score := e.score(e.m)
+	done := e.done(e.m) != 0
+	reward := score - e.lastScore
+	e.lastScore = score
+	if e.maxFrames > 0 && e.frames >= e.maxFrames {
+		done = true
+	}
+	return e.m.Screen(), reward, done
+}
@@ -0,0 +1,72 @@
+package gym
+
+import (
+	"github.com/raidancampbell/gopotato/expr"
+	"testing"
+)
+
+// fakeEmulator counts frames into memory: mem[0] goes up by one a frame while key 5 is held
+type fakeEmulator struct {
+	mem  [4096]byte
+	keys uint16
+}
+
+func (f *fakeEmulator) Peek(addr int) int {
+	return int(f.mem[addr])
+}
+
+func (f *fakeEmulator) Register(r expr.Register) int {
+	return 0
+}
+
+func (f *fakeEmulator) Restart() {
+	f.mem = [4096]byte{}
+}
+
+func (f *fakeEmulator) SetKeys(keys uint16) {
+	f.keys = keys
+}
+
+func (f *fakeEmulator) Screen() (obs Observation) {
+	obs[f.mem[0]%WIDTH][0] = true
+	return
+}
+
+func (f *fakeEmulator) RunFrames(n int) {
+	for frame := 0; frame < n; frame++ {
+		if f.keys&(1<<5) != 0 {
+			f.mem[0]++
+		}
+	}
+}
+
+func TestEnv(t *testing.T) {
+	e, err := New(&fakeEmulator{}, Config{Score: "mem[0]", Done: "mem[0] >= 6", FrameSkip: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obs := e.Reset(); !obs[0][0] {
+		t.Error("the first observation isn't the emulator's screen")
+	}
+	for _, step := range []struct {
+		action uint16
+		reward int
+		done   bool
+	}{
+		{1 << 5, 3, false},
+		{0, 0, false},
+		{1<<5 | 1, 3, true},
+	} {
+		obs, reward, done := e.Step(step.action)
+		if reward != step.reward || done != step.done {
+			t.Errorf("step %#x gave reward %d and done %v, want %d and %v", step.action, reward, done, step.reward, step.done)
+		}
+		if x := int(e.m.(*fakeEmulator).mem[0]); !obs[x][0] {
+			t.Errorf("step %#x didn't observe the screen after it", step.action)
+		}
+	}
+
+	if _, err := New(&fakeEmulator{}, Config{Score: "mem[0] +"}); err == nil {
+		t.Error("a malformed score expression was accepted")
+	}
+}
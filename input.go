@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// inputSource is anything that can hold down keys on the hex keypad
+type inputSource interface {
+	// heldKeys returns which hex keys the source is holding right now, indexed by nibble
+	heldKeys() [16]bool
+}
+
+// virtualKeypad holds hex keys pressed and released by events, rather than polled from a device
+type virtualKeypad struct {
+	sync.Mutex
+	held [16]bool
+}
+
+func (kp *virtualKeypad) set(nibble byte, down bool) {
+	kp.Lock()
+	defer kp.Unlock()
+	kp.held[nibble] = down
+}
+
+func (kp *virtualKeypad) releaseAll() {
+	kp.Lock()
+	defer kp.Unlock()
+	kp.held = [16]bool{}
+}
+
+func (kp *virtualKeypad) heldKeys() [16]bool {
+	kp.Lock()
+	defer kp.Unlock()
+	return kp.held
+}
+
+// the hex key typed as the given character, if it's 0-9 or A-F
+func charToNibble(ch byte) (byte, bool) {
+	switch {
+	case ch >= '0' && ch <= '9':
+		return ch - '0', true
+	case ch >= 'a' && ch <= 'f':
+		return ch - 'a' + 0x0A, true
+	case ch >= 'A' && ch <= 'F':
+		return ch - 'A' + 0x0A, true
+	}
+	return 0, false
+}
+
+// hexKey is a single keypad nibble, written in config files as a hex digit string like "C"
+type hexKey byte
+
+func (k *hexKey) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return fmt.Errorf("hex key must be a string, got %s", b)
+	}
+	n, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(s), "0x"), 16, 8)
+	if err != nil || n > 0x0F {
+		return fmt.Errorf("malformed hex key %q", s)
+	}
+	*k = hexKey(n)
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+// fakeDevice is an input source holding a fixed set of keys
+type fakeDevice [16]bool
+
+func (d *fakeDevice) heldKeys() [16]bool {
+	return *d
+}
+
+// a key held by either of two devices is held, including the ones both hold, and released once neither does
+func TestPollForKeysMergesSources(t *testing.T) {
+	pad, stick := &fakeDevice{}, &fakeDevice{}
+	pad[0x1], pad[0x5] = true, true
+	stick[0x5], stick[0xC] = true, true
+	m := newMachine()
+	m.inputSources = []inputSource{pad, stick}
+
+	m.pollForKeys()
+	for nibble, held := range m.heldKeys() {
+		if want := nibble == 0x1 || nibble == 0x5 || nibble == 0xC; held != want {
+			t.Errorf("key %X held %v, want %v", nibble, held, want)
+		}
+	}
+
+	pad[0x5] = false
+	m.pollForKeys()
+	if !m.isKeyPressed(0x5) {
+		t.Error("5 was released while the other source still held it")
+	}
+	stick[0x5] = false
+	m.pollForKeys()
+	if m.isKeyPressed(0x5) {
+		t.Error("5 is still held after both sources released it")
+	}
+}
@@ -0,0 +1,96 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"fmt"
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"github.com/faiface/pixel/pixelgl"
+	"github.com/faiface/pixel/text"
+)
+
+const (
+	// the on-screen keypad's key size and the gap around them, in window pixels
+	KEYPAD_KEY_SIZE = 28
+	KEYPAD_GAP      = 4
+)
+
+// the COSMAC VIP's keypad, top row first
+var cosmacLayout = [4][4]byte{
+	{0x1, 0x2, 0x3, 0xC},
+	{0x4, 0x5, 0x6, 0xD},
+	{0x7, 0x8, 0x9, 0xE},
+	{0xA, 0x0, 0xB, 0xF},
+}
+
+// keypadOverlay is an on-screen hex keypad in the window's bottom left corner, toggled with F5.  it lights up
+// the keys the game sees held, and holding the mouse button on a key presses it, so games can be played with a mouse alone.
+// unlike the other overlays it stays up while the game runs
+type keypadOverlay struct {
+	open  bool
+	input *virtualKeypad // the keys held with the mouse
+}
+
+// adds the keypad's mouse input to the machine's input sources
+func newKeypadOverlay(m *machine) *keypadOverlay {
+	kp := &keypadOverlay{input: &virtualKeypad{}}
+	m.inputSources = append(m.inputSources, kp.input)
+	return kp
+}
+
+// the window area of the key in the given row and column
+func (kp *keypadOverlay) keyRect(win *pixelgl.Window, row, col int) pixel.Rect {
+	// row 0 is at the top, but the window's origin is at the bottom
+	min := win.Bounds().Min.Add(pixel.V(float64(KEYPAD_GAP+col*(KEYPAD_KEY_SIZE+KEYPAD_GAP)), float64(KEYPAD_GAP+(3-row)*(KEYPAD_KEY_SIZE+KEYPAD_GAP))))
+	return pixel.R(min.X, min.Y, min.X+KEYPAD_KEY_SIZE, min.Y+KEYPAD_KEY_SIZE)
+}
+
+func (kp *keypadOverlay) update(win *pixelgl.Window) {
+	if win.JustPressed(pixelgl.KeyF5) {
+		kp.open = !kp.open
+	}
+	var held [16]bool
+	if kp.open && win.Pressed(pixelgl.MouseButtonLeft) {
+		for row, keys := range cosmacLayout {
+			for col, nibble := range keys {
+				held[nibble] = kp.keyRect(win, row, col).Contains(win.MousePosition())
+			}
+		}
+	}
+	for nibble, down := range held {
+		kp.input.set(byte(nibble), down)
+	}
+}
+
+func (kp *keypadOverlay) draw(win *pixelgl.Window, m *machine) {
+	if !kp.open {
+		return
+	}
+	held := m.heldKeys()
+	p := currentPalette()
+	imd := imdraw.New(nil)
+	txt := text.New(pixel.ZV, text.Atlas7x13)
+	for row, keys := range cosmacLayout {
+		for col, nibble := range keys {
+			r := kp.keyRect(win, row, col)
+			imd.Color = pixel.ToRGBA(p[1]).Mul(pixel.Alpha(0.35))
+			if held[nibble] {
+				imd.Color = pixel.ToRGBA(p[1])
+			}
+			imd.Push(r.Min, r.Max)
+			imd.Rectangle(0)
+
+			label := fmt.Sprintf("%X", nibble)
+			txt.Dot = r.Center().Sub(pixel.V(txt.BoundsOf(label).W()/2, (text.Atlas7x13.Ascent()-text.Atlas7x13.Descent())/2))
+			txt.Color = p[1]
+			if held[nibble] {
+				txt.Color = p[0]
+			}
+			fmt.Fprint(txt, label)
+		}
+	}
+	imd.Draw(win)
+	txt.Draw(win, pixel.IM)
+}
@@ -1,30 +1,373 @@
+//go:build !js
+// +build !js
+
 package main
 
 import (
+	"flag"
 	"fmt"
-	"github.com/faiface/pixel/imdraw"
 	"github.com/faiface/pixel/pixelgl"
+	"math"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime"
 	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
 const (
-	CPU_PROFILE  = false
-	MEM_PROFILE  = false
-	DEBUG_OUTPUT = false
+	CPU_PROFILE = false
+	MEM_PROFILE = false
+	DEFAULT_ROM = "chip8-roms/programs/IBM Logo.ch8"
+	// how long a hotkey's notice, like the theme's name, stays on screen
+	NOTICE_TIME = 2 * time.Second
 )
 
+// subcommands, run as `gopotato <command> [flags] [rom.ch8]`.  without one the ROM is played in a window
+var commands = map[string]func(args []string) error{
+	"achievements": achievementsCommand,
+	"cheats":       cheatsCommand,
+	"gym":          gymCommand,
+	"headless":     headlessCommand,
+	"search":       searchCommand,
+	"serve":        serveCommand,
+	"ssh-serve":    sshServeCommand,
+	"vnc":          vncCommand,
+}
+
 func main() {
-	err := loadROM("chip8-roms/programs/IBM Logo.ch8")
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [achievements|cheats|gym|headless|search|serve|ssh-serve|vnc] [flags] [rom.ch8]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	machineFlags(flag.CommandLine)
+	termMode := flag.Bool("term", false, "render in the terminal instead of a window")
+	braille := flag.Bool("braille", false, "render braille characters instead of half blocks in the terminal")
+	rpcAddr := flag.String("rpc", "", "also serve JSON-RPC on this address, host:port or unix:path")
+	flag.BoolVar(&fractionalScale, "fractional-scale", false, "stretch the display to fill the window instead of scaling by whole numbers")
+	flag.Parse()
+
+	m, err := loadGame(flag.Arg(0))
 	if err != nil {
 		panic(err)
 	}
-	pixelgl.Run(run)
+	if *rpcAddr != "" {
+		if err := startRPC(m, *rpcAddr); err != nil {
+			panic(err)
+		}
+	}
+	if *termMode {
+		err := runTerminal(m, *braille)
+		finishRecording(m)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := romCfg.Gamepads.validate(); err != nil {
+		panic(err)
+	}
+	pixelgl.Run(func() {
+		run(m)
+	})
+}
+
+// the file to record to from startup, see recorder
+var recordPath string
+
+// cheats chosen on the command line
+var cheatFlags struct {
+	path   string
+	enable stringList // names or numbers, counting from 1
+}
+
+// anti-flicker chosen on the command line, overriding the config file
+var flickerFlags flickerConfig
+
+// the image filter chosen on the command line, overriding the config file
+var filterFlag string
+
+// a flag that can be given more than once
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// registers the flags shared by every way of running the ROM given on the command line
+func machineFlags(fs *flag.FlagSet) {
+	sharedMachineFlags(fs)
+	fs.StringVar(&recordPath, "record", "", "record the display to a .gif, .y4m (with a .wav of the buzzer) or .wav file")
+	fs.StringVar(&cheatFlags.path, "cheats", "", "cheat file, instead of the .cheats file beside the ROM")
+	fs.Var(&cheatFlags.enable, "cheat", "switch on the cheat with this name or number, or all of them with \"all\"; can be repeated")
+	fs.StringVar(&filterFlag, "filter", "", "image filter for the window, screenshots and GIFs: none, scale2x, scale3x, hq, scanlines or grid")
+}
+
+// the flags of machineFlags that apply to every machine in the process, for commands like ssh-serve whose
+// machines don't come from loadGame
+func sharedMachineFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&quirks.keyWaitOnPress, "fx0a-on-press", false, "complete Fx0A (wait for key) on key press instead of release")
+	fs.StringVar(&flickerFlags.Mode, "flicker", "", "anti-flicker mode: off, blend, decay or vblank")
+	fs.StringVar(&flickerFlags.Fade, "fade", "", "how long pixels take to fade out in decay mode, e.g. 150ms")
+}
+
+// loads the settings for the ROM at the given path, then a machine with the ROM in memory.
+// an empty path loads the default ROM
+func loadGame(romPath string) (*machine, error) {
+	if romPath == "" {
+		romPath = DEFAULT_ROM
+	}
+	cfg, err := loadConfig(CONFIG_FILE)
+	if err != nil {
+		return nil, err
+	}
+	romCfg = cfg.forROM(romPath)
+	if romCfg.Palette != nil {
+		setTheme(theme(*romCfg.Palette))
+	}
+	if romCfg.Flicker != nil {
+		if err := setAntiFlicker(*romCfg.Flicker); err != nil {
+			return nil, fmt.Errorf("malformed config file %s: %v", CONFIG_FILE, err)
+		}
+	}
+	if err := setAntiFlicker(flickerFlags); err != nil {
+		return nil, err
+	}
+	if romCfg.Filter != "" {
+		if err := setFilter(romCfg.Filter); err != nil {
+			return nil, fmt.Errorf("malformed config file %s: %v", CONFIG_FILE, err)
+		}
+	}
+	if filterFlag != "" {
+		if err := setFilter(filterFlag); err != nil {
+			return nil, err
+		}
+	}
+	m := newMachine()
+	if err := m.loadROM(romPath); err != nil {
+		return nil, err
+	}
+	if m.achievements, err = loadAchievements(romPath); err != nil {
+		return nil, err
+	}
+	if recordPath != "" {
+		if err := startRecording(m, recordPath); err != nil {
+			return nil, err
+		}
+	}
+	return m, loadCommandLineCheats(m, romPath)
+}
+
+// the signals watched for each machine that's recording
+var recordingInterrupts = struct {
+	sync.Mutex
+	watches map[*machine]chan os.Signal
+}{watches: map[*machine]chan os.Signal{}}
+
+// starts recording to the file.  until the recording's finished, ctrl-c or kill save it before exiting,
+// so it isn't left unfinished.  otherwise they're left to the command's own handling
+func startRecording(m *machine, path string) error {
+	if err := m.startRecording(path); err != nil {
+		return err
+	}
+	recordingInterrupts.Lock()
+	defer recordingInterrupts.Unlock()
+	if recordingInterrupts.watches[m] != nil {
+		return nil
+	}
+	sigs := make(chan os.Signal, 1)
+	recordingInterrupts.watches[m] = sigs
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if _, ok := <-sigs; ok {
+			finishRecording(m)
+			os.Exit(1)
+		}
+	}()
+	return nil
 }
 
-func run() {
+// stops the machine's recording, if any, and says where it went
+func finishRecording(m *machine) {
+	recordingInterrupts.Lock()
+	if sigs := recordingInterrupts.watches[m]; sigs != nil {
+		signal.Stop(sigs)
+		delete(recordingInterrupts.watches, m)
+		close(sigs)
+	}
+	recordingInterrupts.Unlock()
+
+	path, err := m.stopRecording()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to save recording %s: %v\n", path, err)
+	} else if path != "" {
+		fmt.Printf("saved recording to %s\n", path)
+	}
+}
+
+// F12 saves the display as a PNG at the window's scale, shift+F12 at 1x, and ctrl+F12 as ASCII art
+func takeScreenshot(win *pixelgl.Window, m *machine, scale float64) {
+	fb := m.disp.snapshot()
+	var path string
+	var err error
+	switch {
+	case win.Pressed(pixelgl.KeyLeftControl) || win.Pressed(pixelgl.KeyRightControl):
+		path = timestampedPath(".txt")
+		err = saveASCIIScreenshot(fb, path)
+		fmt.Print(fb.ascii())
+	case win.Pressed(pixelgl.KeyLeftShift) || win.Pressed(pixelgl.KeyRightShift):
+		path = timestampedPath(".png")
+		err = saveScreenshot(fb, path, 1)
+	default:
+		path = timestampedPath(".png")
+		err = saveScreenshot(fb, path, int(math.Max(1, math.Round(scale))))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to save screenshot: %v\n", err)
+		return
+	}
+	fmt.Printf("saved screenshot to %s\n", path)
+}
+
+// F9 starts and stops recording, to a new timestamped file in the format of -record, or a GIF
+func toggleRecording(m *machine) {
+	if m.isRecording() {
+		finishRecording(m)
+		return
+	}
+	ext := ".gif"
+	if recordPath != "" {
+		ext = filepath.Ext(recordPath)
+	}
+	path := timestampedPath(ext)
+	if err := startRecording(m, path); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	fmt.Printf("recording to %s\n", path)
+}
+
+// installs the cheats in the file beside the ROM, all switched off.  for ROMs other than the one on the
+// command line, which the cheat flags weren't meant for
+func loadGameCheats(m *machine, romPath string) error {
+	cheats, err := loadCheats(cheatPath(romPath))
+	if err != nil {
+		return err
+	}
+	m.setCheats(cheats)
+	return nil
+}
+
+// installs the cheats for the ROM given on the command line, from -cheats if it was given, switching on the ones
+// picked with -cheat.  "all" switches on every cheat there is, even if there are none
+func loadCommandLineCheats(m *machine, romPath string) error {
+	path := cheatFlags.path
+	if path == "" {
+		path = cheatPath(romPath)
+	}
+	cheats, err := loadCheats(path)
+	if err != nil {
+		return err
+	}
+	for _, want := range cheatFlags.enable {
+		found := want == "all"
+		for idx, c := range cheats {
+			if want == "all" || strings.EqualFold(want, c.name) || want == strconv.Itoa(idx+1) {
+				c.enabled = true
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("no cheat %q in %s", want, path)
+		}
+	}
+	m.setCheats(cheats)
+	return nil
+}
+
+// lists a ROM's achievements and which have been unlocked
+func achievementsCommand(args []string) error {
+	fs := flag.NewFlagSet("achievements", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gopotato achievements [rom.ch8]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	romPath := fs.Arg(0)
+	if romPath == "" {
+		romPath = DEFAULT_ROM
+	}
+	t, err := loadAchievements(romPath)
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		fmt.Printf("no achievements in %s\n", achievementPath(romPath))
+		return nil
+	}
+	fmt.Printf("%s: %d/%d unlocked\n", t.rom, len(t.unlocked), len(t.defs))
+	for _, a := range t.defs {
+		if at, ok := t.unlocked[a.ID]; ok {
+			fmt.Printf("  [x] %s - %s (%s)\n", a.Name, a.Description, at.Format("2006-01-02 15:04"))
+		} else {
+			fmt.Printf("  [ ] %s - %s\n", a.Name, a.Description)
+		}
+	}
+	return nil
+}
+
+// lists the cheats in a ROM's cheat file
+func cheatsCommand(args []string) error {
+	fs := flag.NewFlagSet("cheats", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gopotato cheats [flags] rom.ch8\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&cheatFlags.path, "cheats", "", "cheat file, instead of the .cheats file beside the ROM")
+	fs.Parse(args)
+	romPath := fs.Arg(0)
+	if romPath == "" {
+		romPath = DEFAULT_ROM
+	}
+	path := cheatFlags.path
+	if path == "" {
+		path = cheatPath(romPath)
+	}
+	cheats, err := loadCheats(path)
+	if err != nil {
+		return err
+	}
+	if len(cheats) == 0 {
+		fmt.Printf("no cheats in %s\n", path)
+	}
+	for idx, c := range cheats {
+		fmt.Printf("%2d  %s\n", idx+1, c.name)
+	}
+	return nil
+}
+
+func run(m *machine) {
 	if CPU_PROFILE {
 		f, err := os.Create("cpu.pprof")
 		if err != nil {
@@ -38,24 +381,77 @@ func run() {
 	}
 
 	initDisp()
-	go timerTick()
-	go tick()
-	imd := imdraw.New(nil)
-	frames := 0
+	m.inputSources = append(append(m.inputSources, keyboard{window}), gamepadsFor(window, romCfg.Gamepads)...)
+	keypad := newKeypadOverlay(m)
+	debug := &debugPanel{}
+	drawHeat := &drawHeatOverlay{}
+	m.start()
+	scr := newScreen()
+	frames, fps := 0, 0
+	title := ""
 	second := time.Tick(time.Second)
-	for !disp.window.Closed() {
+	overlays := []overlay{&emulationMenu{}, &cheatMenu{}, &searchMenu{}, &memoryPanel{}, &ramPanel{}}
+	var notice string
+	var noticeUntil time.Time
+	for !window.Closed() {
+		if window.JustPressed(pixelgl.KeyF3) {
+			notice, noticeUntil = "theme: "+cycleTheme().name, time.Now().Add(NOTICE_TIME)
+		}
+		if window.JustPressed(pixelgl.KeyF4) {
+			notice, noticeUntil = "filter: "+cycleFilter().name, time.Now().Add(NOTICE_TIME)
+		}
+		if !updateOverlays(overlays, window, m) {
+			if msg := emulationHotkeys(window, m); msg != "" {
+				notice, noticeUntil = msg, time.Now().Add(NOTICE_TIME)
+			}
+		}
+		keypad.update(window)
+		debug.update(window)
+		scr.reserved = debug.width()
+		drawHeat.update(window, m, scr)
+		toast := m.achievements.toast()
+		if toast == "" && time.Now().Before(noticeUntil) {
+			toast = notice
+		}
 
-		drawWindow(imd)
+		if window.JustPressed(pixelgl.KeyF11) {
+			toggleFullscreen(window)
+		}
+		if window.JustPressed(pixelgl.KeyF9) {
+			toggleRecording(m)
+		}
+		if window.JustPressed(pixelgl.KeyF12) {
+			takeScreenshot(window, m, scr.scale())
+		}
+
+		scr.draw(&m.disp)
+		drawHeat.draw(window, m, scr)
+		keypad.draw(window, m)
+		debug.draw(window, m)
+		drawOverlays(overlays, window, m)
+		if toast != "" {
+			drawToast(window, toast)
+		}
+		window.Update()
 
 		frames++
 		select {
 		case <-second:
-			disp.window.SetTitle(fmt.Sprintf("%s | FPS: %d", "gopotato", frames))
-			frames = 0
+			fps, frames = frames, 0
 		default:
 		}
+		newTitle := fmt.Sprintf("%s | FPS: %d", "gopotato", fps) + emulationStatus(m)
+		if m.isRecording() {
+			newTitle += " | recording"
+		}
+		if newTitle != title {
+			window.SetTitle(newTitle)
+			title = newTitle
+		}
 	}
 
+	finishRecording(m)
+
 	if MEM_PROFILE {
 		f, err := os.Create("mem.pprof")
 		if err != nil {
@@ -1,16 +1,17 @@
 package main
 
-import "io/ioutil"
+import (
+	"fmt"
+	"io/ioutil"
+)
 
 type ram [0xFFF]byte
 
-var mem ram
-
 // 0x000 to 0x1FF reserved for interpreter
 // 0x200 start of programs
 
 // set up the system's 5-byte font, starting at location 0x000
-func initRAM() {
+func (m *machine) initRAM() {
 	sprite0 := []byte{0xF0, 0x90, 0x90, 0x90, 0xF0}
 	sprite1 := []byte{0x20, 0x60, 0x20, 0x20, 0x70}
 	sprite2 := []byte{0xF0, 0x10, 0xF0, 0x80, 0xF0}
@@ -27,34 +28,144 @@ func initRAM() {
 	spriteD := []byte{0xE0, 0x90, 0x90, 0x90, 0xE0}
 	spriteE := []byte{0xF0, 0x80, 0xF0, 0x80, 0xF0}
 	spriteF := []byte{0xF0, 0x80, 0xF0, 0x80, 0x80}
-	mem = ram{}
-	copy(mem[5*0x00:], sprite0)
-	copy(mem[5*0x01:], sprite1)
-	copy(mem[5*0x02:], sprite2)
-	copy(mem[5*0x03:], sprite3)
-	copy(mem[5*0x04:], sprite4)
-	copy(mem[5*0x05:], sprite5)
-	copy(mem[5*0x06:], sprite6)
-	copy(mem[5*0x07:], sprite7)
-	copy(mem[5*0x08:], sprite8)
-	copy(mem[5*0x09:], sprite9)
-	copy(mem[5*0x0A:], spriteA)
-	copy(mem[5*0x0B:], spriteB)
-	copy(mem[5*0x0C:], spriteC)
-	copy(mem[5*0x0D:], spriteD)
-	copy(mem[5*0x0E:], spriteE)
-	copy(mem[5*0x0F:], spriteF)
+	m.mem = ram{}
+	copy(m.mem[5*0x00:], sprite0)
+	copy(m.mem[5*0x01:], sprite1)
+	copy(m.mem[5*0x02:], sprite2)
+	copy(m.mem[5*0x03:], sprite3)
+	copy(m.mem[5*0x04:], sprite4)
+	copy(m.mem[5*0x05:], sprite5)
+	copy(m.mem[5*0x06:], sprite6)
+	copy(m.mem[5*0x07:], sprite7)
+	copy(m.mem[5*0x08:], sprite8)
+	copy(m.mem[5*0x09:], sprite9)
+	copy(m.mem[5*0x0A:], spriteA)
+	copy(m.mem[5*0x0B:], spriteB)
+	copy(m.mem[5*0x0C:], spriteC)
+	copy(m.mem[5*0x0D:], spriteD)
+	copy(m.mem[5*0x0E:], spriteE)
+	copy(m.mem[5*0x0F:], spriteF)
 }
 
 func byteToFontLoc(b byte) uint16 {
 	return uint16(5 * b)
 }
 
-func loadROM(path string) error {
+func (m *machine) loadROM(path string) error {
 	b, err := ioutil.ReadFile(path)
 	if err != nil {
 		return err
 	}
-	copy(mem[0x200:], b)
+	m.loadROMBytes(b)
+	m.romPath = path
+	return nil
+}
+
+func (m *machine) loadROMBytes(b []byte) {
+	m.rom = b
+	copy(m.mem[0x200:], b)
+	m.patchCheats()
+}
+
+// every write the program makes to memory goes through here, so frozen addresses keep their values
+func (m *machine) writeMem(addr uint16, b byte) {
+	if frozen, ok := m.frozen[addr]; ok {
+		b = frozen
+	}
+	m.mem[addr] = b
+	if m.access != nil {
+		m.access.written[addr] = true
+		m.access.counts.writes[addr]++
+	}
+}
+
+// every read the program makes of memory as data goes through here.  instruction fetches don't
+func (m *machine) readMem(addr, n uint16) []byte {
+	if m.access != nil {
+		for itr := addr; itr < addr+n && int(itr) < len(m.mem); itr++ {
+			m.access.read[itr] = true
+			m.access.counts.reads[itr]++
+		}
+	}
+	return m.mem[addr : addr+n]
+}
+
+// memAccess records the bytes the program reads and writes, for the memory viewer.  the last whole
+// frame's is kept while the next is recorded
+type memAccess struct {
+	read, written         [len(ram{})]bool
+	lastRead, lastWritten [len(ram{})]bool
+
+	counts memCounts // since tracking began, or the counts were last cleared
+}
+
+// how many times each address has been executed, read and written
+type memCounts struct {
+	executed, reads, writes [len(ram{})]uint32
+}
+
+// starts recording memory accesses, which costs a little on every read and write
+func (m *machine) trackMemoryAccess() {
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	if m.access == nil {
+		m.access = &memAccess{}
+	}
+}
+
+// the bytes read and written during the last frame.  empty unless trackMemoryAccess was called
+func (m *machine) lastMemoryAccess() (read, written [len(ram{})]bool) {
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	if m.access == nil {
+		return
+	}
+	return m.access.lastRead, m.access.lastWritten
+}
+
+// the access counts so far.  empty unless trackMemoryAccess was called
+func (m *machine) memoryAccessCounts() memCounts {
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	if m.access == nil {
+		return memCounts{}
+	}
+	return m.access.counts
+}
+
+// starts the access counts again from zero
+func (m *machine) clearMemoryAccessCounts() {
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	if m.access != nil {
+		m.access.counts = memCounts{}
+	}
+}
+
+// the end of a frame.  callers must hold cpuMutex
+func (a *memAccess) endFrame() {
+	a.lastRead, a.lastWritten = a.read, a.written
+	a.read, a.written = [len(ram{})]bool{}, [len(ram{})]bool{}
+}
+
+// the byte at the address, or 0 outside memory, for memory expressions.  callers must hold cpuMutex or have the machine stopped
+func (m *machine) Peek(addr int) int {
+	if addr < 0 || addr >= len(m.mem) {
+		return 0
+	}
+	return int(m.mem[addr])
+}
+
+// sets a byte of memory from outside the program, only while the machine is paused so it can't race the edit
+func (m *machine) pokeMem(addr int, b byte) error {
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	if !m.paused {
+		return fmt.Errorf("pause the machine to edit memory")
+	}
+	if addr < 0 || addr >= len(m.mem) {
+		return fmt.Errorf("address 0x%03X is outside memory", addr)
+	}
+	m.mem[addr] = b
 	return nil
 }
@@ -0,0 +1,111 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"fmt"
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"github.com/faiface/pixel/pixelgl"
+	"github.com/faiface/pixel/text"
+)
+
+// the window colours for each memory viewer highlight
+var memoryStyleColors = map[int]pixel.RGBA{
+	MEMORY_PLAIN:   pixel.RGB(1, 1, 1),
+	MEMORY_READ:    pixel.RGB(0.4, 0.7, 1),
+	MEMORY_WRITTEN: pixel.RGB(1, 0.5, 0.3),
+	MEMORY_I:       pixel.RGB(0.4, 1, 0.4),
+	MEMORY_PC:      pixel.RGB(1, 1, 0.3),
+	MEMORY_CURSOR:  pixel.RGB(1, 0.4, 1),
+}
+
+// the window's memory viewer and editor, opened with F7.  unlike the menus it doesn't pause the machine,
+// so memory can be watched changing, but P pauses it for editing
+type memoryPanel struct {
+	open bool
+	view memoryView
+	msg  string // the last edit's error
+}
+
+func (panel *memoryPanel) visible() bool {
+	return panel.open
+}
+
+func (panel *memoryPanel) update(win *pixelgl.Window, m *machine) bool {
+	if win.JustPressed(pixelgl.KeyF7) || (panel.open && win.JustPressed(pixelgl.KeyEscape)) {
+		panel.open = !panel.open
+		if panel.open {
+			m.trackMemoryAccess()
+		}
+		return true
+	}
+	if !panel.open {
+		return false
+	}
+
+	pressed := func(button pixelgl.Button) bool {
+		return win.JustPressed(button) || win.Repeated(button)
+	}
+	switch {
+	case pressed(pixelgl.KeyLeft):
+		panel.view.move(-1)
+	case pressed(pixelgl.KeyRight):
+		panel.view.move(1)
+	case pressed(pixelgl.KeyUp):
+		panel.view.move(-MEMORY_VIEW_COLUMNS)
+	case pressed(pixelgl.KeyDown):
+		panel.view.move(MEMORY_VIEW_COLUMNS)
+	case pressed(pixelgl.KeyPageUp):
+		panel.view.move(-MEMORY_VIEW_COLUMNS * MEMORY_VIEW_ROWS)
+	case pressed(pixelgl.KeyPageDown):
+		panel.view.move(MEMORY_VIEW_COLUMNS * MEMORY_VIEW_ROWS)
+	}
+	for _, ch := range win.Typed() {
+		panel.msg = ""
+		switch ch {
+		case 'p', 'P':
+			m.setPaused(!m.isPaused())
+		case 'g', 'G':
+			state := m.saveState()
+			panel.view.jump(int(state.PC))
+		case 'i', 'I':
+			state := m.saveState()
+			panel.view.jump(int(state.I))
+		default:
+			if ch >= 0x80 {
+				continue
+			}
+			if nibble, ok := charToNibble(byte(ch)); ok {
+				if err := panel.view.typeNibble(m, nibble); err != nil {
+					panel.msg = err.Error()
+				}
+			}
+		}
+	}
+	return true
+}
+
+func (panel *memoryPanel) draw(win *pixelgl.Window, m *machine) {
+	bg := imdraw.New(nil)
+	bg.Color = pixel.RGBA{A: 0.85}
+	bg.Push(win.Bounds().Min, win.Bounds().Max)
+	bg.Rectangle(0)
+	bg.Draw(win)
+
+	txt := text.New(pixel.V(8, win.Bounds().H()-8-text.Atlas7x13.Ascent()), text.Atlas7x13)
+	txt.Color = memoryStyleColors[MEMORY_PLAIN]
+	fmt.Fprintln(txt, panel.view.header(m))
+	fmt.Fprintln(txt, "arrows/page up/down move, P pause, G go to PC, I go to I, F7 to close")
+	for _, line := range panel.view.lines(m) {
+		for _, span := range line {
+			txt.Color = memoryStyleColors[span.style]
+			fmt.Fprint(txt, span.text)
+		}
+		fmt.Fprintln(txt)
+	}
+	txt.Color = memoryStyleColors[MEMORY_PLAIN]
+	fmt.Fprintln(txt, panel.msg)
+	txt.Draw(win, pixel.IM)
+}
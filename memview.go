@@ -0,0 +1,152 @@
+package main
+
+import "fmt"
+
+const (
+	// bytes shown per row of the memory viewer, and rows shown at once
+	MEMORY_VIEW_COLUMNS = 8
+	MEMORY_VIEW_ROWS    = 16
+)
+
+// how a span of the memory viewer is highlighted, least important first
+const (
+	MEMORY_PLAIN = iota
+	MEMORY_READ
+	MEMORY_WRITTEN
+	MEMORY_I
+	MEMORY_PC
+	MEMORY_CURSOR
+)
+
+// memorySpan is a piece of a memory viewer line, with its highlight
+type memorySpan struct {
+	text  string
+	style int
+}
+
+// memoryView is a hex editor over a machine's memory, shared by the window's memory panel and the terminal's.
+// each row shows the address and the bytes in hex and as ASCII.  beside them is a separate pane drawing the bytes
+// from the cursor on as a sprite, one per row, so stepping the cursor onto a sprite shows it top to bottom down the
+// side.  bytes read or written in the last frame are highlighted, as are pc and I.  bytes can only be edited while
+// the machine is paused
+type memoryView struct {
+	cursor int    // the selected address
+	top    int    // the first row shown
+	typed  string // the high nibble, once it's been typed
+}
+
+// moves the cursor by delta bytes, scrolling to keep it in view
+func (v *memoryView) move(delta int) {
+	v.cursor += delta
+	if v.cursor < 0 {
+		v.cursor = 0
+	} else if v.cursor >= len(ram{}) {
+		v.cursor = len(ram{}) - 1
+	}
+	v.typed = ""
+	row := v.cursor / MEMORY_VIEW_COLUMNS
+	if row < v.top {
+		v.top = row
+	} else if row >= v.top+MEMORY_VIEW_ROWS {
+		v.top = row - MEMORY_VIEW_ROWS + 1
+	}
+}
+
+// moves the cursor to the address, e.g. pc
+func (v *memoryView) jump(addr int) {
+	v.move(addr - v.cursor)
+}
+
+// takes a typed hex digit.  the second digit of a byte writes it and moves on to the next
+func (v *memoryView) typeNibble(m *machine, nibble byte) error {
+	if !m.isPaused() {
+		return fmt.Errorf("pause the machine to edit memory")
+	}
+	if v.typed == "" {
+		v.typed = fmt.Sprintf("%X", nibble)
+		return nil
+	}
+	var b byte
+	fmt.Sscanf(v.typed, "%X", &b)
+	if err := m.pokeMem(v.cursor, b<<4|nibble); err != nil {
+		return err
+	}
+	v.move(1)
+	return nil
+}
+
+// the rows in view, one line of spans each, under a line heading the columns
+func (v *memoryView) lines(m *machine) [][]memorySpan {
+	read, written := m.lastMemoryAccess()
+	m.cpuMutex.Lock()
+	mem, pc, i := m.mem, int(m.pc), int(m.i)
+	m.cpuMutex.Unlock()
+
+	heading := "    "
+	for col := 0; col < MEMORY_VIEW_COLUMNS; col++ {
+		heading += fmt.Sprintf(" +%X", col)
+	}
+	heading += fmt.Sprintf("  %-*s | sprite from %03X", MEMORY_VIEW_COLUMNS, "ascii", v.cursor)
+	lines := [][]memorySpan{{{heading, MEMORY_PLAIN}}}
+	for row := v.top; row < v.top+MEMORY_VIEW_ROWS && row*MEMORY_VIEW_COLUMNS < len(mem); row++ {
+		start := row * MEMORY_VIEW_COLUMNS
+		line := []memorySpan{{fmt.Sprintf("%03X ", start), MEMORY_PLAIN}}
+		ascii := ""
+		for addr := start; addr < start+MEMORY_VIEW_COLUMNS; addr++ {
+			if addr >= len(mem) {
+				line = append(line, memorySpan{"   ", MEMORY_PLAIN})
+				ascii += " "
+				continue
+			}
+			style := MEMORY_PLAIN
+			switch {
+			case addr == v.cursor:
+				style = MEMORY_CURSOR
+			case addr == pc || addr == pc+1:
+				style = MEMORY_PC
+			case addr == i:
+				style = MEMORY_I
+			case written[addr]:
+				style = MEMORY_WRITTEN
+			case read[addr]:
+				style = MEMORY_READ
+			}
+			text := fmt.Sprintf("%02X", mem[addr])
+			if addr == v.cursor && v.typed != "" {
+				text = v.typed + "_"
+			}
+			line = append(line, memorySpan{" ", MEMORY_PLAIN}, memorySpan{text, style})
+			if mem[addr] >= 0x20 && mem[addr] < 0x7F {
+				ascii += string(rune(mem[addr]))
+			} else {
+				ascii += "."
+			}
+		}
+		line = append(line, memorySpan{"  " + ascii + " | ", MEMORY_PLAIN})
+		// the sprite pane, which follows the cursor rather than the row
+		if addr := v.cursor + row - v.top; addr < len(mem) {
+			sprite := ""
+			for bit := 0; bit < 8; bit++ {
+				if mem[addr]&(0x80>>uint(bit)) != 0 {
+					sprite += "#"
+				} else {
+					sprite += "."
+				}
+			}
+			line = append(line, memorySpan{sprite, MEMORY_PLAIN})
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// the memory viewer's first line: where pc and I point, and whether the machine can be edited
+func (v *memoryView) header(m *machine) string {
+	state := "running"
+	if m.isPaused() {
+		state = "paused, type hex to edit"
+	}
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	return fmt.Sprintf("memory %03X | PC %03X  I %03X | %s", v.cursor, m.pc, m.i, state)
+}
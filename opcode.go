@@ -6,7 +6,7 @@ import (
 
 type opcode struct {
 	matches             func(op uint16) bool
-	exec                func(op uint16)
+	exec                func(m *machine, op uint16)
 	elapsedMicroseconds int
 	name                string
 	description         string
@@ -25,12 +25,12 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op == 0x00E0
 		},
-		exec: func(op uint16) {
-			disp.Lock()
-			defer disp.Unlock()
-			pc += 2
-			disp.fb = framebuffer{}
-			disp.updated = true
+		exec: func(m *machine, op uint16) {
+			m.disp.Lock()
+			defer m.disp.Unlock()
+			m.pc += 2
+			m.disp.fb = framebuffer{}
+			m.disp.updated = true
 		},
 		elapsedMicroseconds: 109,
 		name:                "00E0: CLS",
@@ -40,9 +40,9 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op == 0x00EE
 		},
-		exec: func(op uint16) {
-			sp--
-			pc = stack[sp]
+		exec: func(m *machine, op uint16) {
+			m.sp--
+			m.pc = m.stack[m.sp]
 		},
 		elapsedMicroseconds: 105,
 		name:                "00EE: RET",
@@ -52,8 +52,8 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op >= 0x1000 && op < 0x2000
 		},
-		exec: func(op uint16) {
-			pc = op & 0x0FFF
+		exec: func(m *machine, op uint16) {
+			m.pc = op & 0x0FFF
 		},
 		elapsedMicroseconds: 105,
 		name:                "1nnn: JP addr",
@@ -63,10 +63,10 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op >= 0x2000 && op < 0x3000
 		},
-		exec: func(op uint16) {
-			stack[sp] = pc + 2
-			sp++
-			pc = op & 0x0FFF
+		exec: func(m *machine, op uint16) {
+			m.stack[m.sp] = m.pc + 2
+			m.sp++
+			m.pc = op & 0x0FFF
 		},
 		elapsedMicroseconds: 105,
 		name:                "2nnn: CALL addr",
@@ -76,13 +76,13 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op >= 0x3000 && op < 0x4000
 		},
-		exec: func(op uint16) {
-			v := numToReg(byte((op & 0x0F00) >> (4 * 2)))
+		exec: func(m *machine, op uint16) {
+			v := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
 			val := byte(op & 0x00FF)
 			if *v == val {
-				pc += 2
+				m.pc += 2
 			}
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 55,
 		name:                "3xkk: SE Vx, byte",
@@ -92,13 +92,13 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op >= 0x4000 && op < 0x5000
 		},
-		exec: func(op uint16) {
-			v := numToReg(byte((op & 0x0F00) >> (4 * 2)))
+		exec: func(m *machine, op uint16) {
+			v := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
 			val := byte(op & 0x00FF)
 			if *v != val {
-				pc += 2
+				m.pc += 2
 			}
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 55,
 		name:                "4xkk: SNE Vx, byte",
@@ -108,13 +108,13 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op&0xF00F == 0x5000
 		},
-		exec: func(op uint16) {
-			rx := numToReg(byte((op & 0x0F00) >> (4 * 2)))
-			ry := numToReg(byte((op & 0x00F0) >> (4 * 1)))
+		exec: func(m *machine, op uint16) {
+			rx := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
+			ry := m.numToReg(byte((op & 0x00F0) >> (4 * 1)))
 			if *rx == *ry {
-				pc += 2
+				m.pc += 2
 			}
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 73,
 		name:                "5xy0: SE Vx, Vy",
@@ -124,11 +124,11 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op >= 0x6000 && op < 0x7000
 		},
-		exec: func(op uint16) {
-			r := numToReg(byte((op & 0x0F00) >> (4 * 2)))
+		exec: func(m *machine, op uint16) {
+			r := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
 			val := byte(op & 0x00FF)
 			*r = val
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 27,
 		name:                "6xkk: LD Vx, byte",
@@ -138,11 +138,11 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op >= 0x7000 && op < 0x8000
 		},
-		exec: func(op uint16) {
-			r := numToReg(byte((op & 0x0F00) >> (4 * 2)))
+		exec: func(m *machine, op uint16) {
+			r := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
 			val := byte(op & 0x00FF)
 			*r += val
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 45,
 		name:                "7xkk: ADD Vx, byte",
@@ -152,11 +152,11 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op >= 0x8000 && op < 0x9000 && op&0x000F == 0x0000
 		},
-		exec: func(op uint16) {
-			rx := numToReg(byte((op & 0x0F00) >> (4 * 2)))
-			ry := numToReg(byte((op & 0x00F0) >> (4 * 1)))
+		exec: func(m *machine, op uint16) {
+			rx := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
+			ry := m.numToReg(byte((op & 0x00F0) >> (4 * 1)))
 			*rx = *ry
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 200,
 		name:                "8xy0: LD Vx, Vy",
@@ -166,11 +166,11 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op >= 0x8000 && op < 0x9000 && op&0x000F == 0x0001
 		},
-		exec: func(op uint16) {
-			rx := numToReg(byte((op & 0x0F00) >> (4 * 2)))
-			ry := numToReg(byte((op & 0x00F0) >> (4 * 1)))
+		exec: func(m *machine, op uint16) {
+			rx := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
+			ry := m.numToReg(byte((op & 0x00F0) >> (4 * 1)))
 			*rx |= *ry
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 200,
 		name:                "8xy1: OR Vx, Vy",
@@ -180,11 +180,11 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op >= 0x8000 && op < 0x9000 && op&0x000F == 0x0002
 		},
-		exec: func(op uint16) {
-			rx := numToReg(byte((op & 0x0F00) >> (4 * 2)))
-			ry := numToReg(byte((op & 0x00F0) >> (4 * 1)))
+		exec: func(m *machine, op uint16) {
+			rx := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
+			ry := m.numToReg(byte((op & 0x00F0) >> (4 * 1)))
 			*rx &= *ry
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 200,
 		name:                "8xy2: AND Vx, Vy",
@@ -194,11 +194,11 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op >= 0x8000 && op < 0x9000 && op&0x000F == 0x0003
 		},
-		exec: func(op uint16) {
-			rx := numToReg(byte((op & 0x0F00) >> (4 * 2)))
-			ry := numToReg(byte((op & 0x00F0) >> (4 * 1)))
+		exec: func(m *machine, op uint16) {
+			rx := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
+			ry := m.numToReg(byte((op & 0x00F0) >> (4 * 1)))
 			*rx ^= *ry
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 200,
 		name:                "8xy3: XOR Vx, Vy",
@@ -208,16 +208,16 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op >= 0x8000 && op < 0x9000 && op&0x000F == 0x0004
 		},
-		exec: func(op uint16) {
-			rx := numToReg(byte((op & 0x0F00) >> (4 * 2)))
-			ry := numToReg(byte((op & 0x00F0) >> (4 * 1)))
+		exec: func(m *machine, op uint16) {
+			rx := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
+			ry := m.numToReg(byte((op & 0x00F0) >> (4 * 1)))
 			b := byte(0x00)
 			if int(*rx)+int(*ry) > 255 {
 				b = byte(0x01)
 			}
-			*vf = b
+			*m.vf = b
 			*rx += *ry
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 200,
 		name:                "8xy4: ADD Vx, Vy",
@@ -227,16 +227,16 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op >= 0x8000 && op < 0x9000 && op&0x000F == 0x0005
 		},
-		exec: func(op uint16) {
-			rx := numToReg(byte((op & 0x0F00) >> (4 * 2)))
-			ry := numToReg(byte((op & 0x00F0) >> (4 * 1)))
+		exec: func(m *machine, op uint16) {
+			rx := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
+			ry := m.numToReg(byte((op & 0x00F0) >> (4 * 1)))
 			b := byte(0x00)
 			if *rx > *ry {
 				b = byte(0x01)
 			}
-			*vf = b
+			*m.vf = b
 			*rx -= *ry
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 200,
 		name:                "8xy5: SUB Vx, Vy",
@@ -246,12 +246,12 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op >= 0x8000 && op < 0x9000 && op&0x000F == 0x0006
 		},
-		exec: func(op uint16) {
-			rx := numToReg(byte((op & 0x0F00) >> (4 * 2)))
+		exec: func(m *machine, op uint16) {
+			rx := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
 			b := *rx & 0x01
-			*vf = b
+			*m.vf = b
 			*rx >>= 1
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 200,
 		name:                "8xy6: SHR Vx {, Vy}",
@@ -261,16 +261,16 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op >= 0x8000 && op < 0x9000 && op&0x000F == 0x0007
 		},
-		exec: func(op uint16) {
-			rx := numToReg(byte((op & 0x0F00) >> (4 * 2)))
-			ry := numToReg(byte((op & 0x00F0) >> (4 * 1)))
+		exec: func(m *machine, op uint16) {
+			rx := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
+			ry := m.numToReg(byte((op & 0x00F0) >> (4 * 1)))
 			b := byte(0x00)
 			if *ry > *rx {
 				b = byte(0x01)
 			}
-			*vf = b
+			*m.vf = b
 			*rx = *ry - *rx
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 200,
 		name:                "8xy7: SUBN Vx, Vy",
@@ -280,15 +280,15 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op >= 0x8000 && op < 0x9000 && op&0x000F == 0x000E
 		},
-		exec: func(op uint16) {
-			rx := numToReg(byte((op & 0x0F00) >> (4 * 2)))
+		exec: func(m *machine, op uint16) {
+			rx := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
 			b := *rx & 0x80
 			if b > 0x00 {
 				b = 0x01
 			}
-			*vf = b
+			*m.vf = b
 			*rx <<= 1
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 200,
 		name:                "8xyE: SHL Vx {, Vy}",
@@ -298,13 +298,13 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op >= 0x9000 && op < 0xA000 && op&0x000F == 0x0000
 		},
-		exec: func(op uint16) {
-			rx := numToReg(byte((op & 0x0F00) >> (4 * 2)))
-			ry := numToReg(byte((op & 0x00F0) >> (4 * 1)))
+		exec: func(m *machine, op uint16) {
+			rx := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
+			ry := m.numToReg(byte((op & 0x00F0) >> (4 * 1)))
 			if *rx != *ry {
-				pc += 2
+				m.pc += 2
 			}
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 73,
 		name:                "9xy0: SNE Vx, Vy",
@@ -314,9 +314,9 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op >= 0xA000 && op < 0xB000
 		},
-		exec: func(op uint16) {
-			i = op & 0x0FFF
-			pc += 2
+		exec: func(m *machine, op uint16) {
+			m.i = op & 0x0FFF
+			m.pc += 2
 		},
 		elapsedMicroseconds: 55,
 		name:                "Annn: LD I, addr",
@@ -326,8 +326,8 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op >= 0xB000 && op < 0xC000
 		},
-		exec: func(op uint16) {
-			pc = 0x0FFF + uint16(*v0)
+		exec: func(m *machine, op uint16) {
+			m.pc = 0x0FFF + uint16(*m.v0)
 		},
 		elapsedMicroseconds: 105,
 		name:                "Bnnn: JP V0, addr",
@@ -337,12 +337,12 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op >= 0xC000 && op < 0xD000
 		},
-		exec: func(op uint16) {
-			rx := numToReg(byte((op & 0x0F00) >> (4 * 2)))
+		exec: func(m *machine, op uint16) {
+			rx := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
 			randByte := make([]byte, 1)
 			rand.Read(randByte)
 			*rx = randByte[0] & byte(op&0x00FF)
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 164,
 		name:                "Cxkk: RND Vx, byte",
@@ -352,18 +352,21 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op >= 0xD000 && op < 0xE000
 		},
-		exec: func(op uint16) {
-			sprite := mem[i : i+op&0x000F]
-			rx := numToReg(byte((op & 0x0F00) >> (4 * 2)))
-			ry := numToReg(byte((op & 0x00F0) >> (4 * 1)))
+		exec: func(m *machine, op uint16) {
+			sprite := m.readMem(m.i, op&0x000F)
+			rx := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
+			ry := m.numToReg(byte((op & 0x00F0) >> (4 * 1)))
 
-			didErase := drawSprite(sprite, *rx, *ry)
-			if didErase {
-				*vf = 0x01
+			erased := m.disp.drawSprite(sprite, *rx, *ry)
+			if m.draws != nil {
+				m.draws.sprite(sprite, *rx, *ry, erased, m.pc, m.i)
+			}
+			if len(erased) > 0 {
+				*m.vf = 0x01
 			} else {
-				*vf = 0x00
+				*m.vf = 0x00
 			}
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 22734,
 		name:                "Dxyn: DRW Vx, Vy, nibble",
@@ -373,13 +376,13 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op&0xF0FF == 0xE09E
 		},
-		exec: func(op uint16) {
-			rx := numToReg(byte((op & 0x0F00) >> (4 * 2)))
-			k := isKeyPressed(*rx)
+		exec: func(m *machine, op uint16) {
+			rx := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
+			k := m.isKeyPressed(*rx)
 			if k {
-				pc += 2
+				m.pc += 2
 			}
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 73,
 		name:                "Ex9E: SKP Vx",
@@ -389,13 +392,13 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op&0xF0FF == 0xE0A1
 		},
-		exec: func(op uint16) {
-			rx := numToReg(byte((op & 0x0F00) >> (4 * 2)))
-			k := isKeyPressed(*rx)
+		exec: func(m *machine, op uint16) {
+			rx := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
+			k := m.isKeyPressed(*rx)
 			if !k {
-				pc += 2
+				m.pc += 2
 			}
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 73,
 		name:                "ExA1: SKNP Vx",
@@ -405,10 +408,10 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op&0xF0FF == 0xF007
 		},
-		exec: func(op uint16) {
-			rx := numToReg(byte((op & 0x0F00) >> (4 * 2)))
-			*rx = *dt
-			pc += 2
+		exec: func(m *machine, op uint16) {
+			rx := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
+			*rx = *m.dt
+			m.pc += 2
 		},
 		elapsedMicroseconds: 45,
 		name:                "Fx07: LD Vx, DT",
@@ -418,24 +421,28 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op&0xF0FF == 0xF00A
 		},
-		exec: func(op uint16) {
-			rx := numToReg(byte((op & 0x0F00) >> (4 * 2)))
-			keyWaiting = true
-			*rx = <-keyPress
-			pc += 2
+		exec: func(m *machine, op uint16) {
+			// the pc is left in place until a key is given, so the wait is re-executed each cycle
+			rx := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
+			key, ok := m.pollKeyWait()
+			if !ok {
+				return
+			}
+			*rx = key
+			m.pc += 2
 		},
 		elapsedMicroseconds: 0,
 		name:                "Fx0A: LD Vx, K",
-		description:         "Wait for a key press, store the value of the key in Vx.",
+		description:         "Wait for a key press and release, store the value of the key in Vx.",
 	},
 	{
 		matches: func(op uint16) bool {
 			return op&0xF0FF == 0xF015
 		},
-		exec: func(op uint16) {
-			rx := numToReg(byte((op & 0x0F00) >> (4 * 2)))
-			*dt = *rx
-			pc += 2
+		exec: func(m *machine, op uint16) {
+			rx := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
+			*m.dt = *rx
+			m.pc += 2
 		},
 		elapsedMicroseconds: 45,
 		name:                "Fx15: LD DT, Vx",
@@ -445,10 +452,10 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op&0xF0FF == 0xF018
 		},
-		exec: func(op uint16) {
-			rx := numToReg(byte((op & 0x0F00) >> (4 * 2)))
-			*st = *rx
-			pc += 2
+		exec: func(m *machine, op uint16) {
+			rx := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
+			*m.st = *rx
+			m.pc += 2
 		},
 		elapsedMicroseconds: 45,
 		name:                "Fx18: LD ST, Vx",
@@ -458,16 +465,16 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op&0xF0FF == 0xF01E
 		},
-		exec: func(op uint16) {
-			rx := numToReg(byte((op & 0x0F00) >> (4 * 2)))
-			oldI := i
-			i += uint16(*rx)
-			if oldI > i {
-				*vf = 0x01
+		exec: func(m *machine, op uint16) {
+			rx := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
+			oldI := m.i
+			m.i += uint16(*rx)
+			if oldI > m.i {
+				*m.vf = 0x01
 			} else {
-				*vf = 0x00
+				*m.vf = 0x00
 			}
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 86,
 		name:                "Fx1E: ADD I, Vx",
@@ -477,10 +484,10 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op&0xF0FF == 0xF029
 		},
-		exec: func(op uint16) {
-			rx := numToReg(byte((op & 0x0F00) >> (4 * 2)))
-			i = byteToFontLoc(*rx)
-			pc += 2
+		exec: func(m *machine, op uint16) {
+			rx := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
+			m.i = byteToFontLoc(*rx)
+			m.pc += 2
 		},
 		elapsedMicroseconds: 91,
 		name:                "Fx29: LD F, Vx",
@@ -490,24 +497,24 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op&0xF0FF == 0xF033
 		},
-		exec: func(op uint16) {
-			rx := numToReg(byte((op & 0x0F00) >> (4 * 2)))
+		exec: func(m *machine, op uint16) {
+			rx := m.numToReg(byte((op & 0x0F00) >> (4 * 2)))
 			if *rx >= 100 {
 				hundreds := *rx / 100
-				mem[i] = intToHex(int(hundreds))
+				m.writeMem(m.i, intToHex(int(hundreds)))
 			}
 			if *rx >= 10 {
 				hundreds := *rx / 100
 				tens := (*rx - hundreds*100) / 10
-				mem[i+1] = intToHex(int(tens))
+				m.writeMem(m.i+1, intToHex(int(tens)))
 			}
 			if *rx >= 1 {
 				hundreds := *rx / 100
 				tens := (*rx - hundreds*100) / 10
 				ones := *rx - hundreds*100 - tens*10
-				mem[i+2] = intToHex(int(ones))
+				m.writeMem(m.i+2, intToHex(int(ones)))
 			}
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 927,
 		name:                "Fx33: LD B, Vx",
@@ -517,13 +524,13 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op&0xF0FF == 0xF055
 		},
-		exec: func(op uint16) {
+		exec: func(m *machine, op uint16) {
 			maxReg := byte((op & 0x0F00) >> (4 * 2))
 			for itr := byte(0); itr <= maxReg; itr++ {
-				rx := numToReg(itr)
-				mem[i+uint16(itr)] = *rx
+				rx := m.numToReg(itr)
+				m.writeMem(m.i+uint16(itr), *rx)
 			}
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 605,
 		name:                "Fx55: LD [I], Vx",
@@ -533,13 +540,13 @@ var opcodes = []opcode{
 		matches: func(op uint16) bool {
 			return op&0xF0FF == 0xF065
 		},
-		exec: func(op uint16) {
+		exec: func(m *machine, op uint16) {
 			maxReg := byte((op & 0x0F00) >> (4 * 2))
 			for itr := byte(0); itr <= maxReg; itr++ {
-				rx := numToReg(itr)
-				*rx = mem[i+uint16(itr)]
+				rx := m.numToReg(itr)
+				*rx = m.readMem(m.i+uint16(itr), 1)[0]
 			}
-			pc += 2
+			m.pc += 2
 		},
 		elapsedMicroseconds: 605,
 		name:                "Fx65: LD Vx, [I]",
@@ -0,0 +1,93 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"fmt"
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"github.com/faiface/pixel/pixelgl"
+	"github.com/faiface/pixel/text"
+)
+
+// overlay is a panel drawn over the window's display, opened and closed with a hotkey
+type overlay interface {
+	// handles the overlay's keys, returning whether it used them, because it is or was just open
+	update(win *pixelgl.Window, m *machine) bool
+	visible() bool
+	draw(win *pixelgl.Window, m *machine)
+}
+
+// the open state shared by overlays that pause the machine while they're up
+type pausingPanel struct {
+	open      bool
+	wasPaused bool
+}
+
+func (p *pausingPanel) visible() bool {
+	return p.open
+}
+
+func (p *pausingPanel) toggle(m *machine) {
+	p.open = !p.open
+	if p.open {
+		p.wasPaused = m.isPaused()
+		m.setPaused(true)
+	} else {
+		m.setPaused(p.wasPaused)
+	}
+}
+
+// passes keys to the open overlay, or to each in turn to check their hotkeys when none is open.
+// returns whether an overlay used them
+func updateOverlays(overlays []overlay, win *pixelgl.Window, m *machine) bool {
+	for _, o := range overlays {
+		if o.visible() {
+			return o.update(win, m)
+		}
+	}
+	for _, o := range overlays {
+		if o.update(win, m) {
+			return true
+		}
+	}
+	return false
+}
+
+func drawOverlays(overlays []overlay, win *pixelgl.Window, m *machine) {
+	for _, o := range overlays {
+		if o.visible() {
+			o.draw(win, m)
+		}
+	}
+}
+
+// draws lines of text from the top left of the window, over the dimmed display
+func drawPanel(win *pixelgl.Window, lines []string) {
+	bg := imdraw.New(nil)
+	bg.Color = pixel.RGBA{A: 0.8}
+	bg.Push(win.Bounds().Min, win.Bounds().Max)
+	bg.Rectangle(0)
+	bg.Draw(win)
+
+	txt := text.New(pixel.V(8, win.Bounds().H()-8-text.Atlas7x13.Ascent()), text.Atlas7x13)
+	for _, line := range lines {
+		fmt.Fprintln(txt, line)
+	}
+	txt.Draw(win, pixel.IM)
+}
+
+// draws a line of text in a bar along the bottom of the window, without hiding the display
+func drawToast(win *pixelgl.Window, msg string) {
+	height := text.Atlas7x13.LineHeight() + 8
+	bg := imdraw.New(nil)
+	bg.Color = pixel.RGBA{A: 0.8}
+	bg.Push(win.Bounds().Min, pixel.V(win.Bounds().Max.X, height))
+	bg.Rectangle(0)
+	bg.Draw(win)
+
+	txt := text.New(pixel.V(8, 4-text.Atlas7x13.Descent()), text.Atlas7x13)
+	fmt.Fprint(txt, msg)
+	txt.Draw(win, pixel.IM)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// palette colours the display: the background, then the foreground.  the last two colour the second
+// plane and where both planes overlap, for multi-plane modes
+type palette [4]color.RGBA
+
+// theme is a named palette
+type theme struct {
+	name    string
+	palette palette
+}
+
+// the built-in themes, in the order the hotkey cycles through them
+var themes = []theme{
+	{"classic", palette{rgb(0x000000), rgb(0xFFFFFF), rgb(0xAAAAAA), rgb(0x555555)}},
+	{"green", palette{rgb(0x0A140A), rgb(0x33FF66), rgb(0x1A8033), rgb(0x99FFBB)}},
+	{"amber", palette{rgb(0x140C00), rgb(0xFFB000), rgb(0x805800), rgb(0xFFD870)}},
+	{"lcd", palette{rgb(0x9BBC0F), rgb(0x0F380F), rgb(0x8BAC0F), rgb(0x306230)}},
+	{"octo", palette{rgb(0x996600), rgb(0xFFCC00), rgb(0xFF6600), rgb(0x662200)}},
+}
+
+func rgb(hex uint32) color.RGBA {
+	return color.RGBA{byte(hex >> 16), byte(hex >> 8), byte(hex), 0xFF}
+}
+
+// the theme the display is drawn in, shared by every frontend, screenshots and recordings
+var activeTheme = struct {
+	sync.Mutex
+	theme
+}{theme: themes[0]}
+
+func currentPalette() palette {
+	activeTheme.Lock()
+	defer activeTheme.Unlock()
+	return activeTheme.palette
+}
+
+func currentTheme() theme {
+	activeTheme.Lock()
+	defer activeTheme.Unlock()
+	return activeTheme.theme
+}
+
+func setTheme(t theme) {
+	activeTheme.Lock()
+	defer activeTheme.Unlock()
+	activeTheme.theme = t
+}
+
+// switches to the built-in theme after the active one, returning it.  a custom palette moves on to the first theme
+func cycleTheme() theme {
+	activeTheme.Lock()
+	defer activeTheme.Unlock()
+	next := themes[0]
+	for idx, t := range themes {
+		if t.name == activeTheme.name {
+			next = themes[(idx+1)%len(themes)]
+		}
+	}
+	activeTheme.theme = next
+	return next
+}
+
+// themeSetting is a theme in the config file, written as a built-in theme's name or as a list of two to four
+// "#RRGGBB" colours in palette order.  missing colours repeat the foreground
+type themeSetting theme
+
+func (t *themeSetting) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err == nil {
+		for _, builtin := range themes {
+			if strings.EqualFold(builtin.name, name) {
+				*t = themeSetting(builtin)
+				return nil
+			}
+		}
+		names := make([]string, len(themes))
+		for idx, builtin := range themes {
+			names[idx] = builtin.name
+		}
+		return fmt.Errorf("unknown theme %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+
+	var colors []string
+	if err := json.Unmarshal(b, &colors); err != nil || len(colors) < 2 || len(colors) > 4 {
+		return fmt.Errorf("palette must be a theme name or a list of 2 to 4 colours, got %s", b)
+	}
+	t.name = "custom"
+	for idx := range t.palette {
+		src := colors[1]
+		if idx < len(colors) {
+			src = colors[idx]
+		}
+		c, err := parseHexColor(src)
+		if err != nil {
+			return err
+		}
+		t.palette[idx] = c
+	}
+	return nil
+}
+
+// parses "#RGB" or "#RRGGBB"
+func parseHexColor(s string) (color.RGBA, error) {
+	digits := strings.TrimPrefix(s, "#")
+	if len(digits) == 3 {
+		digits = string([]byte{digits[0], digits[0], digits[1], digits[1], digits[2], digits[2]})
+	}
+	n, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || len(digits) != 6 {
+		return color.RGBA{}, fmt.Errorf("malformed colour %q, expected #RRGGBB", s)
+	}
+	return rgb(uint32(n)), nil
+}
+
+// the colour as "#RRGGBB"
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+}
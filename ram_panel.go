@@ -0,0 +1,153 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"fmt"
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"github.com/faiface/pixel/pixelgl"
+	"github.com/faiface/pixel/text"
+	"math"
+)
+
+const (
+	// the widest row of the RAM bitmap in bytes, and the most it's zoomed in
+	MAX_RAM_ROW_BYTES = 64
+	MAX_RAM_ZOOM      = 8
+	// the window height above the bitmap taken by its text
+	RAM_PANEL_HEADER = 56
+)
+
+// ramPanel shows all of memory as a 1-bit bitmap, opened with F8, with a heatmap of how often each byte was
+// executed, read or written.  sprites and fonts show up as pictures when the row width matches theirs.
+// it doesn't pause the machine, so the heatmap builds up as the game plays
+type ramPanel struct {
+	open    bool
+	width   int // bytes per row
+	top     int // the first row shown
+	heatmap int
+	canvas  *pixelgl.Canvas
+}
+
+func (panel *ramPanel) visible() bool {
+	return panel.open
+}
+
+func (panel *ramPanel) update(win *pixelgl.Window, m *machine) bool {
+	if win.JustPressed(pixelgl.KeyF8) || (panel.open && win.JustPressed(pixelgl.KeyEscape)) {
+		panel.open = !panel.open
+		if panel.open {
+			m.trackMemoryAccess()
+			if panel.width == 0 {
+				panel.width = 1
+			}
+		}
+		return true
+	}
+	if !panel.open {
+		return false
+	}
+
+	_, rows := panel.layout(win)
+	pressed := func(button pixelgl.Button) bool {
+		return win.JustPressed(button) || win.Repeated(button)
+	}
+	switch {
+	case pressed(pixelgl.KeyLeft) && panel.width > 1:
+		panel.width /= 2
+		panel.top *= 2
+	case pressed(pixelgl.KeyRight) && panel.width < MAX_RAM_ROW_BYTES:
+		panel.width *= 2
+		panel.top /= 2
+	case pressed(pixelgl.KeyUp):
+		panel.top -= 8
+	case pressed(pixelgl.KeyDown):
+		panel.top += 8
+	case pressed(pixelgl.KeyPageUp):
+		panel.top -= rows
+	case pressed(pixelgl.KeyPageDown):
+		panel.top += rows
+	}
+	for _, ch := range win.Typed() {
+		switch ch {
+		case 'h', 'H':
+			panel.heatmap = (panel.heatmap + 1) % HEATMAP_MODES
+		case 'c', 'C':
+			m.clearMemoryAccessCounts()
+		}
+	}
+	panel.scroll(rows)
+	return true
+}
+
+// keeps the rows shown within memory
+func (panel *ramPanel) scroll(rows int) {
+	total := (len(ram{}) + panel.width - 1) / panel.width
+	if panel.top > total-rows {
+		panel.top = total - rows
+	}
+	if panel.top < 0 {
+		panel.top = 0
+	}
+}
+
+// how much the bitmap is zoomed in to fill the window's width, and how many of its rows fit
+func (panel *ramPanel) layout(win *pixelgl.Window) (zoom, rows int) {
+	zoom = int(math.Min(float64(MAX_RAM_ZOOM), (win.Bounds().W()-16)/float64(panel.width*8)))
+	if zoom < 1 {
+		zoom = 1
+	}
+	rows = int(win.Bounds().H()-RAM_PANEL_HEADER-8) / zoom
+	if rows < 1 {
+		rows = 1
+	}
+	return zoom, rows
+}
+
+func (panel *ramPanel) draw(win *pixelgl.Window, m *machine) {
+	bg := imdraw.New(nil)
+	bg.Color = pixel.RGBA{A: 0.85}
+	bg.Push(win.Bounds().Min, win.Bounds().Max)
+	bg.Rectangle(0)
+	bg.Draw(win)
+
+	zoom, rows := panel.layout(win)
+	panel.scroll(rows)
+	counts := m.memoryAccessCounts()
+	m.cpuMutex.Lock()
+	mem := m.mem
+	m.cpuMutex.Unlock()
+	start := panel.top * panel.width
+	end := start + rows*panel.width
+	if end > len(mem) {
+		end = len(mem)
+		rows = (end - start + panel.width - 1) / panel.width
+	}
+
+	img := ramBitmap(mem[:], &counts, start, panel.width, rows, panel.heatmap, currentPalette())
+	b := img.Bounds()
+	if panel.canvas == nil {
+		panel.canvas = pixelgl.NewCanvas(pixel.R(0, 0, float64(b.Dx()), float64(b.Dy())))
+	} else if panel.canvas.Bounds().W() != float64(b.Dx()) || panel.canvas.Bounds().H() != float64(b.Dy()) {
+		panel.canvas.SetBounds(pixel.R(0, 0, float64(b.Dx()), float64(b.Dy())))
+	}
+	panel.canvas.SetPixels(glPixels(img, nil))
+	topLeft := pixel.V(8, win.Bounds().H()-RAM_PANEL_HEADER)
+	size := pixel.V(float64(b.Dx()*zoom), float64(b.Dy()*zoom))
+	panel.canvas.Draw(win, pixel.IM.Scaled(pixel.ZV, float64(zoom)).Moved(topLeft.Add(pixel.V(size.X/2, -size.Y/2))))
+
+	txt := text.New(pixel.V(8, win.Bounds().H()-8-text.Atlas7x13.Ascent()), text.Atlas7x13)
+	fmt.Fprintf(txt, "RAM %03X-%03X | %d bytes a row | heatmap %s\n", start, end-1, panel.width, heatmapNames[panel.heatmap])
+	fmt.Fprintln(txt, "left/right row width, up/down/page scroll, H heatmap, C clear, F8 to close")
+	// the byte under the mouse
+	mouse := win.MousePosition().Sub(topLeft)
+	x, row := int(math.Floor(mouse.X/float64(zoom))), int(math.Floor(-mouse.Y/float64(zoom)))
+	if x >= 0 && x < panel.width*8 && row >= 0 && row < rows {
+		if addr := start + row*panel.width + x/8; addr < len(mem) {
+			fmt.Fprintf(txt, "%03X = %02X | executed %d, read %d, written %d", addr, mem[addr], counts.executed[addr], counts.reads[addr], counts.writes[addr])
+		}
+	}
+	txt.Draw(win, pixel.IM)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// locations searched past the end of memory are the V registers
+const SEARCH_LOCATIONS = len(ram{}) + 16
+
+// ramSearch narrows down where a game keeps a variable, like lives or score, by taking snapshots of memory
+// and the V registers and keeping the locations whose values changed the way the variable did
+type ramSearch struct {
+	candidates []int    // surviving locations, in order
+	history    [][]byte // every snapshot since the search began, oldest first
+}
+
+// the filters, comparing each location in a new snapshot with the one before
+var searchFilters = map[string]func(prev, cur byte) bool{
+	"equal":     func(prev, cur byte) bool { return cur == prev },
+	"changed":   func(prev, cur byte) bool { return cur != prev },
+	"increased": func(prev, cur byte) bool { return cur > prev },
+	"decreased": func(prev, cur byte) bool { return cur < prev },
+}
+
+// starts a search with every location a candidate
+func newRAMSearch(m *machine) *ramSearch {
+	s := &ramSearch{candidates: make([]int, SEARCH_LOCATIONS)}
+	for loc := range s.candidates {
+		s.candidates[loc] = loc
+	}
+	s.history = [][]byte{m.searchSnapshot()}
+	return s
+}
+
+func (m *machine) searchSnapshot() []byte {
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	snap := make([]byte, SEARCH_LOCATIONS)
+	copy(snap, m.mem[:])
+	for nibble := byte(0); nibble <= 0x0F; nibble++ {
+		snap[len(ram{})+int(nibble)] = *m.numToReg(nibble)
+	}
+	return snap
+}
+
+// takes a snapshot and keeps the candidates that changed since the last one the way the named filter says
+func (s *ramSearch) filter(m *machine, name string) error {
+	keep, ok := searchFilters[name]
+	if !ok {
+		return fmt.Errorf("unknown filter %q, expected equal, changed, increased or decreased", name)
+	}
+	prev := s.history[len(s.history)-1]
+	cur := m.searchSnapshot()
+	s.history = append(s.history, cur)
+	s.keep(func(loc int) bool { return keep(prev[loc], cur[loc]) })
+	return nil
+}
+
+// takes a snapshot and keeps the candidates holding the value
+func (s *ramSearch) filterValue(m *machine, value byte) {
+	cur := m.searchSnapshot()
+	s.history = append(s.history, cur)
+	s.keep(func(loc int) bool { return cur[loc] == value })
+}
+
+func (s *ramSearch) keep(pred func(loc int) bool) {
+	kept := s.candidates[:0]
+	for _, loc := range s.candidates {
+		if pred(loc) {
+			kept = append(kept, loc)
+		}
+	}
+	s.candidates = kept
+}
+
+// a location as written in expressions and cheat files
+func searchLocationName(loc int) string {
+	if loc >= len(ram{}) {
+		return fmt.Sprintf("v%x", loc-len(ram{}))
+	}
+	return fmt.Sprintf("0x%03X", loc)
+}
+
+// the first limit candidates, each with its values in the last few snapshots, oldest first
+func (s *ramSearch) describe(limit, snapshots int) []string {
+	first := len(s.history) - snapshots
+	if first < 0 {
+		first = 0
+	}
+	var lines []string
+	for idx, loc := range s.candidates {
+		if idx == limit {
+			lines = append(lines, fmt.Sprintf("... and %d more", len(s.candidates)-limit))
+			break
+		}
+		values := make([]string, 0, snapshots)
+		for _, snap := range s.history[first:] {
+			values = append(values, fmt.Sprintf("%3d", snap[loc]))
+		}
+		lines = append(lines, fmt.Sprintf("%-5s %s", searchLocationName(loc), strings.Join(values, " ")))
+	}
+	return lines
+}
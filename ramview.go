@@ -0,0 +1,85 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// what the RAM bitmap's heatmap shows
+const (
+	HEATMAP_OFF = iota
+	HEATMAP_EXECUTED
+	HEATMAP_READ
+	HEATMAP_WRITTEN
+	HEATMAP_ALL
+	HEATMAP_MODES
+)
+
+var heatmapNames = [HEATMAP_MODES]string{"off", "executed", "read", "written", "all"}
+
+// how strongly the hottest bytes are tinted
+const HEATMAP_OPACITY = 0.7
+
+// memory drawn as a 1-bit image, widthBytes bytes to a row with each byte's most significant bit leftmost, so
+// sprite data stands out from code and blank space.  it starts at the address start and runs for rows rows.
+// with a heatmap each byte is tinted by how often it was accessed, on a log scale against the busiest byte:
+// green for executed, blue for read, red for written
+func ramBitmap(mem []byte, counts *memCounts, start, widthBytes, rows, heatmap int, p palette) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, widthBytes*8, rows))
+	var busiest [3]uint32
+	for addr := range mem {
+		for idx, n := range [3]uint32{counts.executed[addr], counts.reads[addr], counts.writes[addr]} {
+			if n > busiest[idx] {
+				busiest[idx] = n
+			}
+		}
+	}
+	heat := func(n, max uint32) float64 {
+		if max == 0 {
+			return 0
+		}
+		return math.Log1p(float64(n)) / math.Log1p(float64(max))
+	}
+
+	for row := 0; row < rows; row++ {
+		for col := 0; col < widthBytes; col++ {
+			addr := start + row*widthBytes + col
+			if addr >= len(mem) {
+				continue
+			}
+			executed := heat(counts.executed[addr], busiest[0])
+			read := heat(counts.reads[addr], busiest[1])
+			written := heat(counts.writes[addr], busiest[2])
+			var tint color.RGBA
+			var strength float64
+			switch heatmap {
+			case HEATMAP_EXECUTED:
+				tint, strength = color.RGBA{0, 0xFF, 0, 0xFF}, executed
+			case HEATMAP_READ:
+				tint, strength = color.RGBA{0x40, 0x80, 0xFF, 0xFF}, read
+			case HEATMAP_WRITTEN:
+				tint, strength = color.RGBA{0xFF, 0x30, 0x30, 0xFF}, written
+			case HEATMAP_ALL:
+				tint = color.RGBA{byte(0xFF * written), byte(0xFF * executed), byte(0xFF * read), 0xFF}
+				strength = math.Max(executed, math.Max(read, written))
+			}
+			for bit := 0; bit < 8; bit++ {
+				c := p[0]
+				if mem[addr]&(0x80>>uint(bit)) != 0 {
+					c = p[1]
+				}
+				img.SetRGBA(col*8+bit, row, blend(c, tint, strength*HEATMAP_OPACITY))
+			}
+		}
+	}
+	return img
+}
+
+// the colour a weight of the way from a to b
+func blend(a, b color.RGBA, weight float64) color.RGBA {
+	mix := func(x, y byte) byte {
+		return byte(float64(x) + (float64(y)-float64(x))*weight + 0.5)
+	}
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 0xFF}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// the buzzer's pitch, in the browser and in recordings
+	BUZZER_HZ = 440
+	// recordings are scaled up from the CHIP-8's 64x32 so players don't blur them
+	RECORD_SCALE = 4
+	// buzzer audio in WAV recordings
+	RECORD_SAMPLE_RATE = 44100
+	RECORD_VOLUME      = 0x2000
+	// the shortest GIF frame delay players honour, in hundredths of a second
+	MIN_GIF_DELAY = 2
+)
+
+// recorder captures the display and buzzer once per emulated frame, so recordings keep the game's own
+// timing however fast or slow it's actually running.  the format is picked by the file extension:
+// .gif for an animated GIF, .y4m for uncompressed video with the buzzer in a .wav beside it, or .wav for sound alone
+type recorder struct {
+	path   string
+	gif    bool
+	filter filter // GIF frames are filtered as the recording started, so they all share a size
+
+	// GIF frames are kept packed until the recording stops, identical frames merged into one
+	gifFrames [][]byte
+	gifLength []int // how many emulated frames each GIF frame lasts
+
+	video    *os.File
+	videoBuf *bufio.Writer
+
+	audio       *os.File
+	audioBuf    *bufio.Writer
+	audioBytes  int
+	audioPhase  int // samples into the square wave, kept across frames so the tone doesn't click
+	audioFrames int // emulated frames captured, for spreading samples evenly
+}
+
+// the default name for a recording or screenshot taken from the keyboard, numbered if there's already one from this second
+func timestampedPath(ext string) string {
+	base := "gopotato-" + time.Now().Format("20060102-150405")
+	path := base + ext
+	for n := 2; ; n++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = fmt.Sprintf("%s-%d%s", base, n, ext)
+	}
+}
+
+func newRecorder(path string) (*recorder, error) {
+	r := &recorder{path: path, filter: currentFilter()}
+	var err error
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".gif":
+		r.gif = true
+	case ".y4m":
+		if r.video, err = os.Create(path); err != nil {
+			return nil, err
+		}
+		r.videoBuf = bufio.NewWriter(r.video)
+		fmt.Fprintf(r.videoBuf, "YUV4MPEG2 W%d H%d F60:1 Ip A1:1 C444\n", XRES*RECORD_SCALE, YRES*RECORD_SCALE)
+		if err = r.createAudio(strings.TrimSuffix(path, filepath.Ext(path)) + ".wav"); err != nil {
+			r.video.Close()
+			return nil, err
+		}
+	case ".wav":
+		if err = r.createAudio(path); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("can't record to %q, expected a .gif, .y4m or .wav file", ext)
+	}
+	return r, nil
+}
+
+func (r *recorder) createAudio(path string) error {
+	var err error
+	if r.audio, err = os.Create(path); err != nil {
+		return err
+	}
+	r.audioBuf = bufio.NewWriter(r.audio)
+	// sizes are filled in once the recording stops
+	return writeWAVHeader(r.audioBuf, 0)
+}
+
+// 16 bit mono PCM
+func writeWAVHeader(w io.Writer, dataBytes int) error {
+	header := []interface{}{
+		[4]byte{'R', 'I', 'F', 'F'}, uint32(36 + dataBytes), [4]byte{'W', 'A', 'V', 'E'},
+		[4]byte{'f', 'm', 't', ' '}, uint32(16), uint16(1), uint16(1),
+		uint32(RECORD_SAMPLE_RATE), uint32(RECORD_SAMPLE_RATE * 2), uint16(2), uint16(16),
+		[4]byte{'d', 'a', 't', 'a'}, uint32(dataBytes),
+	}
+	for _, field := range header {
+		if err := binary.Write(w, binary.LittleEndian, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// adds one emulated frame
+func (r *recorder) capture(fb framebuffer, buzzing bool) {
+	if r.gif {
+		packed := fb.pack()
+		last := len(r.gifFrames) - 1
+		if last >= 0 && string(r.gifFrames[last]) == string(packed) {
+			r.gifLength[last]++
+		} else {
+			r.gifFrames = append(r.gifFrames, packed)
+			r.gifLength = append(r.gifLength, 1)
+		}
+	}
+
+	if r.videoBuf != nil {
+		img := fb.image(RECORD_SCALE)
+		planes := [3][]byte{}
+		for idx := range planes {
+			planes[idx] = make([]byte, 0, len(img.Pix))
+		}
+		// the palette is tiny, so convert it once rather than every pixel
+		ycbcr := make([][3]byte, len(img.Palette))
+		for idx, c := range img.Palette {
+			red, green, blue, _ := c.RGBA()
+			y, cb, cr := color.RGBToYCbCr(uint8(red>>8), uint8(green>>8), uint8(blue>>8))
+			ycbcr[idx] = [3]byte{y, cb, cr}
+		}
+		for _, colorIdx := range img.Pix {
+			for plane := range planes {
+				planes[plane] = append(planes[plane], ycbcr[colorIdx][plane])
+			}
+		}
+		r.videoBuf.WriteString("FRAME\n")
+		for _, plane := range planes {
+			r.videoBuf.Write(plane)
+		}
+	}
+
+	if r.audioBuf != nil {
+		// samples up to the end of this frame, so rounding never drifts
+		r.audioFrames++
+		samples := r.audioFrames*RECORD_SAMPLE_RATE/60 - r.audioBytes/2
+		halfPeriod := RECORD_SAMPLE_RATE / BUZZER_HZ / 2
+		buf := make([]byte, 2*samples)
+		for itr := 0; itr < samples; itr++ {
+			sample := int16(0)
+			if buzzing {
+				sample = RECORD_VOLUME
+				if r.audioPhase/halfPeriod%2 == 1 {
+					sample = -RECORD_VOLUME
+				}
+			}
+			r.audioPhase++
+			binary.LittleEndian.PutUint16(buf[2*itr:], uint16(sample))
+		}
+		r.audioBuf.Write(buf)
+		r.audioBytes += len(buf)
+	}
+}
+
+// finishes the recording's files, every one of them even if another fails, returning the first error
+func (r *recorder) close() error {
+	var errs []error
+	if r.gif && len(r.gifFrames) > 0 {
+		errs = append(errs, r.writeGIF())
+	}
+	if r.video != nil {
+		errs = append(errs, r.videoBuf.Flush(), r.video.Close())
+	}
+	if r.audio != nil {
+		errs = append(errs, r.closeAudio())
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finishes the WAV's header now its length is known, closing it whatever goes wrong
+func (r *recorder) closeAudio() error {
+	err := r.audioBuf.Flush()
+	if err == nil {
+		_, err = r.audio.Seek(0, io.SeekStart)
+	}
+	if err == nil {
+		err = writeWAVHeader(r.audio, r.audioBytes)
+	}
+	if closeErr := r.audio.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (r *recorder) writeGIF() error {
+	anim := &gif.GIF{}
+	// GIF delays are in hundredths of a second, so each is rounded against the running total to stay in sync.
+	// players show delays under MIN_GIF_DELAY as a tenth of a second, so a frame that short is dropped and its
+	// time carried into the next one
+	frames, delays := 0, 0
+	for idx, packed := range r.gifFrames {
+		frames += r.gifLength[idx]
+		delay := frames*100/60 - delays
+		if delay < MIN_GIF_DELAY {
+			if idx < len(r.gifFrames)-1 {
+				continue
+			}
+			delay = MIN_GIF_DELAY
+		}
+		delays += delay
+		anim.Image = append(anim.Image, unpackFramebuffer(packed).filtered(r.filter, RECORD_SCALE))
+		anim.Delay = append(anim.Delay, delay)
+	}
+	size := anim.Image[0].Bounds()
+	anim.Config = image.Config{Width: size.Dx(), Height: size.Dy(), ColorModel: anim.Image[0].Palette}
+
+	f, err := os.Create(r.path)
+	if err != nil {
+		return err
+	}
+	if err := gif.EncodeAll(f, anim); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// starts capturing every frame to the file
+func (m *machine) startRecording(path string) error {
+	r, err := newRecorder(path)
+	if err != nil {
+		return err
+	}
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	if m.recorder != nil {
+		m.recorder.close()
+	}
+	m.recorder = r
+	return nil
+}
+
+// finishes the recording, if there is one, returning where it was saved
+func (m *machine) stopRecording() (string, error) {
+	m.cpuMutex.Lock()
+	r := m.recorder
+	m.recorder = nil
+	m.cpuMutex.Unlock()
+	if r == nil {
+		return "", nil
+	}
+	return r.path, r.close()
+}
+
+func (m *machine) isRecording() bool {
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	return m.recorder != nil
+}
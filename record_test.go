@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/binary"
+	"image/gif"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// a display that changes every frame comes out at no less than the shortest delay players honour, in sync with the game
+func TestGIFDelays(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clip.gif")
+	r, err := newRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const frames = 60
+	for n := 0; n < frames; n++ {
+		var fb framebuffer
+		fb[n%XRES][0] = true
+		r.capture(fb, false)
+	}
+	if err := r.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	anim, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for idx, delay := range anim.Delay {
+		if delay < MIN_GIF_DELAY {
+			t.Errorf("frame %d has a delay of %dcs", idx, delay)
+		}
+		total += delay
+	}
+	if want := frames * 100 / 60; total != want {
+		t.Errorf("delays add up to %dcs, want %dcs", total, want)
+	}
+}
+
+// when the video can't be finished, the buzzer's WAV beside it still is, and the error is returned
+func TestCloseFinishesEveryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clip.y4m")
+	r, err := newRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < 30; n++ {
+		r.capture(framebuffer{}, true)
+	}
+	// the video's file goes away under the recorder, so flushing it fails
+	r.video.Close()
+	if err := r.close(); err == nil {
+		t.Error("close succeeded without writing the video")
+	}
+
+	wav, err := ioutil.ReadFile(strings.TrimSuffix(path, ".y4m") + ".wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wav) <= 44 {
+		t.Fatalf("the WAV is only %d bytes", len(wav))
+	}
+	if riff, want := binary.LittleEndian.Uint32(wav[4:]), uint32(len(wav)-8); riff != want {
+		t.Errorf("RIFF size is %d, want %d", riff, want)
+	}
+	if data, want := binary.LittleEndian.Uint32(wav[40:]), uint32(len(wav)-44); data != want {
+		t.Errorf("data size is %d, want %d", data, want)
+	}
+}
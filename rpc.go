@@ -0,0 +1,474 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image/png"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	RPC_PARSE_ERROR      = -32700
+	RPC_METHOD_NOT_FOUND = -32601
+	RPC_INVALID_PARAMS   = -32602
+	RPC_SERVER_ERROR     = -32000
+)
+
+// rpcServer lets scripts control a machine with JSON-RPC 2.0 requests, one per line
+type rpcServer struct {
+	m     *machine
+	input *virtualKeypad // keys held through pressKey
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// the machine's registers as returned by getRegisters, step and runFrames
+type rpcRegisters struct {
+	V      [16]byte   `json:"v"`
+	I      uint16     `json:"i"`
+	PC     uint16     `json:"pc"`
+	SP     byte       `json:"sp"`
+	Stack  [16]uint16 `json:"stack"`
+	DT     byte       `json:"dt"`
+	ST     byte       `json:"st"`
+	Paused bool       `json:"paused"`
+}
+
+// every method takes its params by name
+var rpcMethods = map[string]func(s *rpcServer, params json.RawMessage) (interface{}, error){
+	"load":           (*rpcServer).load,
+	"reset":          (*rpcServer).reset,
+	"pause":          (*rpcServer).pause,
+	"resume":         (*rpcServer).resume,
+	"step":           (*rpcServer).step,
+	"runFrames":      (*rpcServer).runFrames,
+	"pressKey":       (*rpcServer).pressKey,
+	"releaseKey":     (*rpcServer).releaseKey,
+	"readMemory":     (*rpcServer).readMemory,
+	"writeMemory":    (*rpcServer).writeMemory,
+	"getRegisters":   (*rpcServer).getRegisters,
+	"getFramebuffer": (*rpcServer).getFramebuffer,
+	"screenshot":     (*rpcServer).screenshot,
+	"saveState":      (*rpcServer).saveState,
+	"loadState":      (*rpcServer).loadState,
+}
+
+// starts serving RPC for the machine in the background.  the address is a TCP host:port,
+// or a unix socket path prefixed with "unix:".  call before the machine starts
+func startRPC(m *machine, addr string) error {
+	network := "tcp"
+	if strings.HasPrefix(addr, "unix:") {
+		network, addr = "unix", strings.TrimPrefix(addr, "unix:")
+		os.Remove(addr) // a stale socket from an earlier run
+	}
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	s := &rpcServer{m: m, input: &virtualKeypad{}}
+	m.inputSources = append(m.inputSources, s.input)
+	fmt.Fprintf(os.Stderr, "serving JSON-RPC on %s %s\n", network, l.Addr())
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.handleConn(conn)
+		}
+	}()
+	return nil
+}
+
+// runs a ROM with no display at all, controlled over RPC
+func headlessCommand(args []string) error {
+	fs := flag.NewFlagSet("headless", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gopotato headless [flags] [rom.ch8]\n")
+		fs.PrintDefaults()
+	}
+	addr := fs.String("rpc", "unix:gopotato.sock", "address to serve JSON-RPC on, host:port or unix:path")
+	paused := fs.Bool("paused", false, "start paused, so the ROM only runs when stepped")
+	machineFlags(fs)
+	fs.Parse(args)
+	m, err := loadGame(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := startRPC(m, *addr); err != nil {
+		return err
+	}
+	m.paused = *paused
+	m.start()
+	select {}
+}
+
+func (s *rpcServer) handleConn(conn net.Conn) {
+	defer conn.Close()
+	// the input is read line by line so one malformed request doesn't end the session
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(nil, 1<<20)
+	enc := json.NewEncoder(conn)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var req rpcRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			enc.Encode(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{RPC_PARSE_ERROR, err.Error()}})
+			continue
+		}
+		resp := s.call(req)
+		if req.ID == nil {
+			continue // a notification
+		}
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+func (s *rpcServer) call(req rpcRequest) (resp rpcResponse) {
+	resp = rpcResponse{JSONRPC: "2.0", ID: req.ID}
+	method, ok := rpcMethods[req.Method]
+	if !ok {
+		resp.Error = &rpcError{RPC_METHOD_NOT_FOUND, fmt.Sprintf("no such method %q", req.Method)}
+		return resp
+	}
+	// a ROM stepped into garbage panics on the bad opcode; report it rather than dying
+	defer func() {
+		if r := recover(); r != nil {
+			resp.Result = nil
+			resp.Error = &rpcError{RPC_SERVER_ERROR, fmt.Sprint(r)}
+		}
+	}()
+	result, err := method(s, req.Params)
+	if err != nil {
+		if rpcErr, ok := err.(*rpcError); ok {
+			resp.Error = rpcErr
+		} else {
+			resp.Error = &rpcError{RPC_SERVER_ERROR, err.Error()}
+		}
+		return resp
+	}
+	if result == nil {
+		result = true
+	}
+	resp.Result = result
+	return resp
+}
+
+// unmarshals named params, which may be left out entirely
+func parseParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{RPC_INVALID_PARAMS, err.Error()}
+	}
+	return nil
+}
+
+func invalidParams(format string, args ...interface{}) error {
+	return &rpcError{RPC_INVALID_PARAMS, fmt.Sprintf(format, args...)}
+}
+
+func (s *rpcServer) registers() rpcRegisters {
+	s.m.cpuMutex.Lock()
+	defer s.m.cpuMutex.Unlock()
+	regs := rpcRegisters{
+		I:      s.m.i,
+		PC:     s.m.pc,
+		SP:     s.m.sp,
+		Stack:  s.m.stack,
+		DT:     *s.m.dt,
+		ST:     *s.m.st,
+		Paused: s.m.paused,
+	}
+	for nibble := byte(0); nibble <= 0x0F; nibble++ {
+		regs.V[nibble] = *s.m.numToReg(nibble)
+	}
+	return regs
+}
+
+// {"path": "rom.ch8"}
+func (s *rpcServer) load(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Path string `json:"path"`
+	}
+	if err := parseParams(params, &p); err != nil {
+		return nil, err
+	}
+	rom, err := ioutil.ReadFile(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	achievements, err := loadAchievements(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	s.m.setCheats(nil) // the last ROM's cheats mustn't patch this one
+	s.m.load(rom)
+	s.m.cpuMutex.Lock()
+	s.m.achievements = achievements
+	s.m.romPath = p.Path
+	s.m.cpuMutex.Unlock()
+	return nil, loadGameCheats(s.m, p.Path)
+}
+
+func (s *rpcServer) reset(params json.RawMessage) (interface{}, error) {
+	s.m.restart()
+	return nil, nil
+}
+
+func (s *rpcServer) pause(params json.RawMessage) (interface{}, error) {
+	s.m.setPaused(true)
+	return nil, nil
+}
+
+func (s *rpcServer) resume(params json.RawMessage) (interface{}, error) {
+	s.m.setPaused(false)
+	return nil, nil
+}
+
+// {"n": 1}.  meant for a paused machine, which otherwise keeps running in between
+func (s *rpcServer) step(params json.RawMessage) (interface{}, error) {
+	p := struct {
+		N int `json:"n"`
+	}{N: 1}
+	if err := parseParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.N < 0 {
+		return nil, invalidParams("n must not be negative")
+	}
+	s.m.stepInstructions(p.N)
+	return s.registers(), nil
+}
+
+// {"n": 1}.  runs as fast as possible rather than in real time
+func (s *rpcServer) runFrames(params json.RawMessage) (interface{}, error) {
+	p := struct {
+		N int `json:"n"`
+	}{N: 1}
+	if err := parseParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.N < 0 {
+		return nil, invalidParams("n must not be negative")
+	}
+	s.m.runFrames(p.N)
+	return s.registers(), nil
+}
+
+// {"key": "A"}
+func (s *rpcServer) pressKey(params json.RawMessage) (interface{}, error) {
+	return nil, s.setKey(params, true)
+}
+
+func (s *rpcServer) releaseKey(params json.RawMessage) (interface{}, error) {
+	return nil, s.setKey(params, false)
+}
+
+func (s *rpcServer) setKey(params json.RawMessage, down bool) error {
+	var p struct {
+		Key *hexKey `json:"key"`
+	}
+	if err := parseParams(params, &p); err != nil {
+		return err
+	}
+	if p.Key == nil {
+		return invalidParams("missing key")
+	}
+	s.input.set(byte(*p.Key), down)
+	s.m.pollForKeys() // make the key visible straight away, even while paused
+	return nil
+}
+
+// checks that length bytes starting at address lie within memory
+func (s *rpcServer) checkRange(address, length int) error {
+	if address < 0 || length < 0 || address+length > len(s.m.mem) {
+		return invalidParams("0x%X bytes at 0x%X is outside memory", length, address)
+	}
+	return nil
+}
+
+// {"address": 512, "length": 16}, returning {"data": [...]}
+func (s *rpcServer) readMemory(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Address int `json:"address"`
+		Length  int `json:"length"`
+	}
+	if err := parseParams(params, &p); err != nil {
+		return nil, err
+	}
+	if err := s.checkRange(p.Address, p.Length); err != nil {
+		return nil, err
+	}
+	s.m.cpuMutex.Lock()
+	defer s.m.cpuMutex.Unlock()
+	data := make([]int, p.Length)
+	for idx := range data {
+		data[idx] = int(s.m.mem[p.Address+idx])
+	}
+	return map[string]interface{}{"data": data}, nil
+}
+
+// {"address": 512, "data": [...]}
+func (s *rpcServer) writeMemory(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Address int   `json:"address"`
+		Values  []int `json:"data"`
+	}
+	if err := parseParams(params, &p); err != nil {
+		return nil, err
+	}
+	if err := s.checkRange(p.Address, len(p.Values)); err != nil {
+		return nil, err
+	}
+	for _, v := range p.Values {
+		if v < 0 || v > 0xFF {
+			return nil, invalidParams("%d is not a byte", v)
+		}
+	}
+	s.m.cpuMutex.Lock()
+	defer s.m.cpuMutex.Unlock()
+	for idx, v := range p.Values {
+		s.m.mem[p.Address+idx] = byte(v)
+	}
+	return nil, nil
+}
+
+func (s *rpcServer) getRegisters(params json.RawMessage) (interface{}, error) {
+	return s.registers(), nil
+}
+
+// {"format": "png", "scale": 1} returns {"width", "height", "png"} with the image base64 encoded.
+// {"format": "bits"} returns {"width", "height", "bits"} with one array of 0s and 1s per row.
+// {"format": "ascii"} returns {"width", "height", "ascii"}, see framebuffer.ascii
+func (s *rpcServer) getFramebuffer(params json.RawMessage) (interface{}, error) {
+	p := struct {
+		Format string `json:"format"`
+		Scale  int    `json:"scale"`
+	}{Format: "png", Scale: 1}
+	if err := parseParams(params, &p); err != nil {
+		return nil, err
+	}
+	fb := s.m.disp.snapshot()
+	switch p.Format {
+	case "png":
+		if p.Scale < 1 || p.Scale > 64 {
+			return nil, invalidParams("scale must be between 1 and 64")
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, fb.image(p.Scale)); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"width":  XRES * p.Scale,
+			"height": YRES * p.Scale,
+			"png":    base64.StdEncoding.EncodeToString(buf.Bytes()),
+		}, nil
+	case "bits":
+		rows := make([][]int, YRES)
+		for y := range rows {
+			rows[y] = make([]int, XRES)
+			for x := range rows[y] {
+				if fb[x][y] {
+					rows[y][x] = 1
+				}
+			}
+		}
+		return map[string]interface{}{"width": XRES, "height": YRES, "bits": rows}, nil
+	case "ascii":
+		return map[string]interface{}{"width": XRES, "height": YRES, "ascii": fb.ascii()}, nil
+	}
+	return nil, invalidParams("unknown format %q, expected png, bits or ascii", p.Format)
+}
+
+// {"path": "shot.png", "scale": 1} saves the display to a file, a PNG or ASCII art if the path ends in .txt,
+// returning {"path"}.  without a path it's named after the time
+func (s *rpcServer) screenshot(params json.RawMessage) (interface{}, error) {
+	p := struct {
+		Path  string `json:"path"`
+		Scale int    `json:"scale"`
+	}{Scale: 1}
+	if err := parseParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Scale < 1 || p.Scale > 64 {
+		return nil, invalidParams("scale must be between 1 and 64")
+	}
+	if p.Path == "" {
+		p.Path = timestampedPath(".png")
+	}
+	if err := saveScreenshotAs(s.m.disp.snapshot(), p.Path, p.Scale); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"path": p.Path}, nil
+}
+
+// {"path": "save.json"} writes the state to a file; without a path the state is returned
+func (s *rpcServer) saveState(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Path string `json:"path"`
+	}
+	if err := parseParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Path == "" {
+		return s.m.saveState(), nil
+	}
+	return nil, s.m.saveStateFile(p.Path)
+}
+
+// {"path": "save.json"} or {"state": {...}} as returned by saveState
+func (s *rpcServer) loadState(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Path  string        `json:"path"`
+		State *machineState `json:"state"`
+	}
+	if err := parseParams(params, &p); err != nil {
+		return nil, err
+	}
+	switch {
+	case p.State != nil:
+		return nil, s.m.loadState(*p.State)
+	case p.Path != "":
+		return nil, s.m.loadStateFile(p.Path)
+	}
+	return nil, invalidParams("expected a path or a state")
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writes the framebuffer to a PNG through the active filter, each pixel scaled up to about a scale by scale square
+func saveScreenshot(fb framebuffer, path string, scale int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, fb.filtered(currentFilter(), scale)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// the framebuffer as text, # for lit pixels and . for dark, one line per row
+func (fb framebuffer) ascii() string {
+	var b strings.Builder
+	for y := 0; y < YRES; y++ {
+		for x := 0; x < XRES; x++ {
+			if fb[x][y] {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// writes the framebuffer as text, see ascii
+func saveASCIIScreenshot(fb framebuffer, path string) error {
+	return ioutil.WriteFile(path, []byte(fb.ascii()), 0644)
+}
+
+// takes a screenshot, a PNG or text depending on the file extension
+func saveScreenshotAs(fb framebuffer, path string, scale int) error {
+	if strings.EqualFold(filepath.Ext(path), ".txt") {
+		return saveASCIIScreenshot(fb, path)
+	}
+	return saveScreenshot(fb, path, scale)
+}
@@ -0,0 +1,125 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const searchHelp = `commands:
+  run [frames]       run the game, 60 frames by default
+  press <key>        hold a hex key down
+  release <key>      let a hex key go
+  screen             show the display
+  equal              keep locations that haven't changed since the last filter
+  changed            keep locations that have
+  increased          keep locations that went up
+  decreased          keep locations that went down
+  value <n>          keep locations holding n
+  list [n]           show the first n candidates, 20 by default, with their recent values
+  new                start the search over
+  quit
+`
+
+// a REPL for finding game variables in a ROM that only runs when told to
+func searchCommand(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gopotato search [flags] [rom.ch8]\n")
+		fs.PrintDefaults()
+	}
+	machineFlags(fs)
+	fs.Parse(args)
+	m, err := loadGame(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	input := &virtualKeypad{}
+	m.inputSources = []inputSource{input}
+	runSearchREPL(m, input, os.Stdin, os.Stdout)
+	finishRecording(m)
+	return nil
+}
+
+func runSearchREPL(m *machine, input *virtualKeypad, in io.Reader, out io.Writer) {
+	search := newRAMSearch(m)
+	fmt.Fprintf(out, "%d candidates.  type help for commands\n", len(search.candidates))
+	scanner := bufio.NewScanner(in)
+	for fmt.Fprint(out, "> "); scanner.Scan(); fmt.Fprint(out, "> ") {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		arg := func(def int) (int, error) {
+			if len(fields) < 2 {
+				return def, nil
+			}
+			n, err := strconv.ParseInt(fields[1], 0, 32)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("malformed number %q", fields[1])
+			}
+			return int(n), nil
+		}
+
+		switch cmd := fields[0]; cmd {
+		case "run":
+			frames, err := arg(60)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			m.pollForKeys()
+			m.runFrames(frames)
+		case "press", "release":
+			if len(fields) != 2 || len(fields[1]) != 1 {
+				fmt.Fprintf(out, "%s takes a hex key\n", cmd)
+				continue
+			}
+			nibble, ok := charToNibble(fields[1][0])
+			if !ok {
+				fmt.Fprintf(out, "malformed hex key %q\n", fields[1])
+				continue
+			}
+			input.set(nibble, cmd == "press")
+		case "screen":
+			fmt.Fprint(out, m.disp.snapshot().ascii())
+		case "equal", "changed", "increased", "decreased":
+			search.filter(m, cmd)
+			fmt.Fprintf(out, "%d candidates\n", len(search.candidates))
+		case "value":
+			value, err := arg(-1)
+			if err != nil || value < 0 || value > 0xFF {
+				fmt.Fprintln(out, "value takes a byte")
+				continue
+			}
+			search.filterValue(m, byte(value))
+			fmt.Fprintf(out, "%d candidates\n", len(search.candidates))
+		case "list":
+			limit, err := arg(20)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			for _, line := range search.describe(limit, 8) {
+				fmt.Fprintln(out, line)
+			}
+		case "new":
+			search = newRAMSearch(m)
+			fmt.Fprintf(out, "%d candidates\n", len(search.candidates))
+		case "help":
+			fmt.Fprint(out, searchHelp)
+		case "quit", "exit":
+			return
+		default:
+			fmt.Fprintf(out, "unknown command %q.  type help for commands\n", cmd)
+		}
+	}
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,68 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"fmt"
+	"github.com/faiface/pixel/pixelgl"
+	"strconv"
+)
+
+// the window's RAM search, opened with F2.  the machine is paused while it's open, so play a little between filters
+type searchMenu struct {
+	pausingPanel
+	search *ramSearch
+	typed  string // a value being typed in
+}
+
+func (menu *searchMenu) update(win *pixelgl.Window, m *machine) bool {
+	if win.JustPressed(pixelgl.KeyF2) || (menu.open && win.JustPressed(pixelgl.KeyEscape)) {
+		menu.toggle(m)
+		if menu.search == nil {
+			menu.search = newRAMSearch(m)
+		}
+		menu.typed = ""
+		return true
+	}
+	if !menu.open {
+		return false
+	}
+
+	for _, ch := range win.Typed() {
+		switch {
+		case ch >= '0' && ch <= '9':
+			menu.typed += string(ch)
+		case ch == 'e':
+			menu.search.filter(m, "equal")
+		case ch == 'c':
+			menu.search.filter(m, "changed")
+		case ch == 'i':
+			menu.search.filter(m, "increased")
+		case ch == 'd':
+			menu.search.filter(m, "decreased")
+		case ch == 'n':
+			menu.search = newRAMSearch(m)
+		}
+	}
+	if win.JustPressed(pixelgl.KeyBackspace) && menu.typed != "" {
+		menu.typed = menu.typed[:len(menu.typed)-1]
+	}
+	if win.JustPressed(pixelgl.KeyEnter) && menu.typed != "" {
+		if value, err := strconv.Atoi(menu.typed); err == nil && value <= 0xFF {
+			menu.search.filterValue(m, byte(value))
+		}
+		menu.typed = ""
+	}
+	return true
+}
+
+func (menu *searchMenu) draw(win *pixelgl.Window, m *machine) {
+	lines := []string{
+		fmt.Sprintf("RAM search | %d candidates | F2 to close", len(menu.search.candidates)),
+		"e equal, c changed, i increased, d decreased, n new search",
+		fmt.Sprintf("value: %s_ (enter to keep locations holding it)", menu.typed),
+		"",
+	}
+	drawPanel(win, append(lines, menu.search.describe(16, 8)...))
+}
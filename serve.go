@@ -0,0 +1,206 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// frames queued for a client before it's considered too slow and dropped
+const STREAM_SEND_BUFFER = 64
+
+// streamServer runs the emulator headless and streams the display to browsers over WebSocket.
+// the longest-connected client is in control, everyone else spectates
+type streamServer struct {
+	sync.Mutex
+	m       *machine
+	clients []*streamClient // in order of arrival
+	input   *virtualKeypad
+	fb      framebuffer // as last sent to clients
+}
+
+type streamClient struct {
+	conn *websocket.Conn
+	send chan []byte
+}
+
+// sent to clients.  a full frame packs the pixels row by row, most significant bit first;
+// a diff lists the pixels to flip, numbered row by row
+type streamMessage struct {
+	Type    string `json:"type"`
+	Role    string `json:"role,omitempty"`
+	Viewers int    `json:"viewers,omitempty"`
+	Width   int    `json:"width,omitempty"`
+	Height  int    `json:"height,omitempty"`
+	Bits    []byte `json:"bits,omitempty"`
+	Flip    []int  `json:"flip,omitempty"`
+	// colours as "#RRGGBB", in palette order
+	Palette []string `json:"palette,omitempty"`
+}
+
+// sent by clients when a hex key is pressed or released
+type streamKeyEvent struct {
+	Type string `json:"type"`
+	Key  hexKey `json:"key"`
+	Down bool   `json:"down"`
+}
+
+var upgrader = websocket.Upgrader{}
+
+func serveCommand(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gopotato serve [flags] [rom.ch8]\n")
+		fs.PrintDefaults()
+	}
+	addr := fs.String("addr", ":8080", "address to serve the web client on")
+	machineFlags(fs)
+	fs.Parse(args)
+	m, err := loadGame(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	s := &streamServer{m: m, input: &virtualKeypad{}}
+	m.inputSources = []inputSource{s.input}
+	m.start()
+	go s.stream()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, streamClientHTML)
+	})
+	mux.HandleFunc("/ws", s.handleWS)
+	fmt.Printf("serving on %s\n", *addr)
+	return http.ListenAndServe(*addr, mux)
+}
+
+// sends the pixels that changed to every client, once per frame
+func (s *streamServer) stream() {
+	for range time.Tick(16667 * time.Microsecond) {
+		fb := s.m.disp.shown()
+
+		var flips []int
+		for x := 0; x < XRES; x++ {
+			for y := 0; y < YRES; y++ {
+				if fb[x][y] != s.fb[x][y] {
+					flips = append(flips, y*XRES+x)
+				}
+			}
+		}
+		if len(flips) == 0 {
+			continue
+		}
+		msg, _ := json.Marshal(streamMessage{Type: "diff", Flip: flips})
+		s.Lock()
+		s.fb = fb
+		for _, c := range s.clients {
+			s.queue(c, msg)
+		}
+		s.Unlock()
+	}
+}
+
+// the whole display as clients last saw it.  callers must hold the lock
+func (s *streamServer) fullFrame() []byte {
+	var colors []string
+	for _, c := range currentPalette() {
+		colors = append(colors, hexColor(c))
+	}
+	msg, _ := json.Marshal(streamMessage{Type: "full", Width: XRES, Height: YRES, Bits: s.fb.pack(), Palette: colors})
+	return msg
+}
+
+// tells every client whether it's in control.  callers must hold the lock
+func (s *streamServer) announceRoles() {
+	for idx, c := range s.clients {
+		role := "spectator"
+		if idx == 0 {
+			role = "controller"
+		}
+		msg, _ := json.Marshal(streamMessage{Type: "role", Role: role, Viewers: len(s.clients)})
+		s.queue(c, msg)
+	}
+}
+
+// hands a message to the client's writer, disconnecting clients that have fallen too far behind.
+// callers must hold the lock
+func (s *streamServer) queue(c *streamClient, msg []byte) {
+	select {
+	case c.send <- msg:
+	default:
+		c.conn.Close()
+	}
+}
+
+func (s *streamServer) handleWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &streamClient{conn: conn, send: make(chan []byte, STREAM_SEND_BUFFER)}
+	go c.write()
+
+	s.Lock()
+	s.clients = append(s.clients, c)
+	s.queue(c, s.fullFrame())
+	s.announceRoles()
+	s.Unlock()
+	defer s.remove(c)
+
+	for {
+		var ev streamKeyEvent
+		if err := conn.ReadJSON(&ev); err != nil {
+			return
+		}
+		if ev.Type != "key" {
+			continue
+		}
+		s.Lock()
+		inControl := s.clients[0] == c
+		s.Unlock()
+		if inControl {
+			s.input.set(byte(ev.Key), ev.Down)
+		}
+	}
+}
+
+// disconnects the client, passing control to the next in line if it had it
+func (s *streamServer) remove(c *streamClient) {
+	s.Lock()
+	defer s.Unlock()
+	for idx := range s.clients {
+		if s.clients[idx] != c {
+			continue
+		}
+		if idx == 0 {
+			s.input.releaseAll()
+		}
+		s.clients = append(s.clients[:idx], s.clients[idx+1:]...)
+		break
+	}
+	close(c.send)
+	s.announceRoles()
+}
+
+func (c *streamClient) write() {
+	defer c.conn.Close()
+	for msg := range c.send {
+		if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,77 @@
+//go:build !js
+// +build !js
+
+package main
+
+// the browser client served by `gopotato serve`
+const streamClientHTML = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>gopotato</title>
+  <style>
+    body { background: #222; color: #ddd; font-family: monospace; text-align: center; }
+    #screen { width: 640px; max-width: 100%; image-rendering: pixelated; image-rendering: crisp-edges; background: #000; }
+  </style>
+</head>
+<body>
+  <canvas id="screen" width="64" height="32"></canvas>
+  <div id="status">connecting...</div>
+  <script>
+    const canvas = document.getElementById("screen");
+    const ctx = canvas.getContext("2d");
+    const status = document.getElementById("status");
+    let width = 64, height = 32, pixels = new Uint8Array(width * height), controlling = false;
+    let colors = ["#000", "#fff"];
+
+    function draw(idx) {
+      ctx.fillStyle = colors[pixels[idx]];
+      ctx.fillRect(idx % width, Math.floor(idx / width), 1, 1);
+    }
+
+    const ws = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/ws");
+    ws.onclose = () => { status.textContent = "disconnected"; };
+    ws.onmessage = (ev) => {
+      const msg = JSON.parse(ev.data);
+      switch (msg.type) {
+      case "full":
+        width = msg.width; height = msg.height;
+        colors = msg.palette || colors;
+        canvas.width = width; canvas.height = height;
+        pixels = new Uint8Array(width * height);
+        const bits = atob(msg.bits);
+        for (let idx = 0; idx < pixels.length; idx++) {
+          pixels[idx] = (bits.charCodeAt(idx >> 3) >> (7 - (idx & 7))) & 1;
+          draw(idx);
+        }
+        break;
+      case "diff":
+        for (const idx of msg.flip) {
+          pixels[idx] ^= 1;
+          draw(idx);
+        }
+        break;
+      case "role":
+        controlling = msg.role === "controller";
+        status.textContent = (controlling ? "you are in control" : "spectating") + " | " + msg.viewers + " connected";
+        break;
+      }
+    };
+
+    function onKey(down) {
+      return (ev) => {
+        if (ev.key.length !== 1 || !/[0-9a-f]/i.test(ev.key)) {
+          return;
+        }
+        ev.preventDefault();
+        if (controlling && !ev.repeat) {
+          ws.send(JSON.stringify({type: "key", key: ev.key, down: down}));
+        }
+      };
+    }
+    document.addEventListener("keydown", onKey(true));
+    document.addEventListener("keyup", onKey(false));
+  </script>
+</body>
+</html>
+`
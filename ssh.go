@@ -0,0 +1,306 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// sshServer gives every SSH session its own machine, a ROM picker and a terminal display
+type sshServer struct {
+	config  *ssh.ServerConfig
+	romDir  string
+	braille bool
+}
+
+// one shell session, from the ROM picker through to the games it runs
+type sshSession struct {
+	sync.Mutex
+	srv  *sshServer
+	ch   ssh.Channel
+	rows int
+}
+
+func sshServeCommand(args []string) error {
+	fs := flag.NewFlagSet("ssh-serve", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gopotato ssh-serve [flags]\n")
+		fs.PrintDefaults()
+	}
+	addr := fs.String("addr", ":2222", "address to listen for SSH connections on")
+	romDir := fs.String("roms", "chip8-roms", "directory of ROMs to offer")
+	hostKeyPath := fs.String("host-key", "gopotato_host_key", "host key file, generated if it doesn't exist")
+	braille := fs.Bool("braille", false, "render braille characters instead of half blocks")
+	// sessions pick their ROM from a menu, so the flags for the command line's ROM don't apply
+	sharedMachineFlags(fs)
+	fs.Parse(args)
+	// sessions share the display settings, so only the command line's apply
+	if err := setAntiFlicker(flickerFlags); err != nil {
+		return err
+	}
+
+	hostKey, err := loadHostKey(*hostKeyPath)
+	if err != nil {
+		return err
+	}
+	// anyone who can reach the port can play
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(hostKey)
+	srv := &sshServer{config: config, romDir: *romDir, braille: *braille}
+
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("serving SSH on %s with host key %s\n", l.Addr(), ssh.FingerprintSHA256(hostKey.PublicKey()))
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go srv.handleConn(conn)
+	}
+}
+
+// loads the server's host key, generating and saving an ed25519 key the first time
+func loadHostKey(path string) (ssh.Signer, error) {
+	pemBytes, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		pemBytes = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		if err := ioutil.WriteFile(path, pemBytes, 0600); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return ssh.ParsePrivateKey(pemBytes)
+}
+
+func (srv *sshServer) handleConn(nConn net.Conn) {
+	conn, chans, reqs, err := ssh.NewServerConn(nConn, srv.config)
+	if err != nil {
+		nConn.Close()
+		return
+	}
+	defer conn.Close()
+	go ssh.DiscardRequests(reqs)
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			newCh.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		ch, requests, err := newCh.Accept()
+		if err != nil {
+			continue
+		}
+		sess := &sshSession{srv: srv, ch: ch, rows: 24}
+		go sess.handleRequests(requests)
+	}
+}
+
+func (sess *sshSession) handleRequests(requests <-chan *ssh.Request) {
+	started := false
+	for req := range requests {
+		switch req.Type {
+		case "pty-req":
+			var pty struct {
+				Term                         string
+				Columns, Rows, Width, Height uint32
+				Modes                        string
+			}
+			if err := ssh.Unmarshal(req.Payload, &pty); err == nil {
+				sess.setRows(int(pty.Rows))
+			}
+			req.Reply(true, nil)
+		case "window-change":
+			var size struct {
+				Columns, Rows, Width, Height uint32
+			}
+			if err := ssh.Unmarshal(req.Payload, &size); err == nil {
+				sess.setRows(int(size.Rows))
+			}
+		case "shell":
+			req.Reply(!started, nil)
+			if !started {
+				started = true
+				go func() {
+					sess.run()
+					sess.ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+					sess.ch.Close()
+				}()
+			}
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+func (sess *sshSession) setRows(rows int) {
+	sess.Lock()
+	defer sess.Unlock()
+	if rows > 0 {
+		sess.rows = rows
+	}
+}
+
+// alternates between the ROM picker and the picked game until the player quits
+func (sess *sshSession) run() {
+	roms, err := findROMs(sess.srv.romDir)
+	if err != nil || len(roms) == 0 {
+		fmt.Fprintf(sess.ch, "no ROMs found in %s\r\n", sess.srv.romDir)
+		return
+	}
+	cursor := 0
+	for {
+		var ok bool
+		cursor, ok = sess.pick(roms, cursor)
+		if !ok {
+			fmt.Fprint(sess.ch, "\x1b[0m\x1b[2J\x1b[H")
+			return
+		}
+		m := newMachine()
+		romPath := filepath.Join(sess.srv.romDir, roms[cursor])
+		if err := m.loadROM(romPath); err != nil {
+			fmt.Fprintf(sess.ch, "%v\r\n", err)
+			return
+		}
+		if m.achievements, err = loadAchievements(romPath); err != nil {
+			fmt.Fprintf(sess.ch, "%v\r\n", err)
+			return
+		}
+		if err := loadGameCheats(m, romPath); err != nil {
+			fmt.Fprintf(sess.ch, "%v\r\n", err)
+			return
+		}
+		m.start()
+		runTerminalSession(m, sess.ch, sess.ch, sess.srv.braille)
+		m.stop()
+	}
+}
+
+// every ROM beneath the directory, relative to it
+func findROMs(dir string) ([]string, error) {
+	var roms []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if !info.IsDir() && (ext == ".ch8" || ext == ".c8") {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			roms = append(roms, rel)
+		}
+		return nil
+	})
+	sort.Strings(roms)
+	return roms, err
+}
+
+// shows the ROM list and moves the cursor until a ROM is picked, returning false if the player quits instead
+func (sess *sshSession) pick(roms []string, cursor int) (int, bool) {
+	buf := make([]byte, 32)
+	for {
+		sess.Lock()
+		pageSize := sess.rows - 3
+		sess.Unlock()
+		if pageSize < 1 {
+			pageSize = 1
+		}
+		sess.drawMenu(roms, cursor, pageSize)
+
+		n, err := sess.ch.Read(buf)
+		if err != nil {
+			return cursor, false
+		}
+		for in := buf[:n]; len(in) > 0; {
+			move, key, size := pickerKey(in, pageSize)
+			in = in[size:]
+			cursor += move
+			switch key {
+			case '\r', '\n':
+				return clampCursor(cursor, len(roms)), true
+			case 'q', 0x03, 0x04: // ctrl-c, ctrl-d
+				return clampCursor(cursor, len(roms)), false
+			}
+		}
+		cursor = clampCursor(cursor, len(roms))
+	}
+}
+
+// the first key in the picker's input as a cursor movement or a plain key, and how many bytes it took
+func pickerKey(in []byte, pageSize int) (move int, key byte, size int) {
+	escapes := []struct {
+		seq  string
+		move int
+	}{
+		{"\x1b[A", -1},
+		{"\x1bOA", -1},
+		{"\x1b[B", 1},
+		{"\x1bOB", 1},
+		{"\x1b[5~", -pageSize},
+		{"\x1b[6~", pageSize},
+	}
+	for _, esc := range escapes {
+		if bytes.HasPrefix(in, []byte(esc.seq)) {
+			return esc.move, 0, len(esc.seq)
+		}
+	}
+	switch in[0] {
+	case 'k':
+		return -1, 0, 1
+	case 'j':
+		return 1, 0, 1
+	}
+	return 0, in[0], 1
+}
+
+func clampCursor(cursor, count int) int {
+	if cursor >= count {
+		cursor = count - 1
+	}
+	if cursor < 0 {
+		cursor = 0
+	}
+	return cursor
+}
+
+func (sess *sshSession) drawMenu(roms []string, cursor, pageSize int) {
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[0m\x1b[2J\x1b[H")
+	fmt.Fprintf(&buf, "gopotato | %d ROMs | up/down or j/k to move, enter to play, q to quit\r\n\r\n", len(roms))
+	first := cursor / pageSize * pageSize
+	for idx := first; idx < len(roms) && idx < first+pageSize; idx++ {
+		if idx == cursor {
+			fmt.Fprintf(&buf, "\x1b[7m> %s\x1b[0m\r\n", roms[idx])
+		} else {
+			fmt.Fprintf(&buf, "  %s\r\n", roms[idx])
+		}
+	}
+	sess.ch.Write(buf.Bytes())
+}
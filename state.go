@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// machineState is a snapshot of everything needed to resume a machine later, as saved to disk
+type machineState struct {
+	V     [16]byte   `json:"v"`
+	I     uint16     `json:"i"`
+	PC    uint16     `json:"pc"`
+	SP    byte       `json:"sp"`
+	Stack [16]uint16 `json:"stack"`
+	DT    byte       `json:"dt"`
+	ST    byte       `json:"st"`
+	Mem   []byte     `json:"mem"`
+	FB    []byte     `json:"fb"` // packed row by row, see framebuffer.pack
+	ROM   []byte     `json:"rom"`
+}
+
+func (m *machine) saveState() machineState {
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	state := machineState{
+		I:     m.i,
+		PC:    m.pc,
+		SP:    m.sp,
+		Stack: m.stack,
+		DT:    *m.dt,
+		ST:    *m.st,
+		Mem:   append([]byte(nil), m.mem[:]...),
+		FB:    m.disp.snapshot().pack(),
+		ROM:   m.rom,
+	}
+	for nibble := byte(0); nibble <= 0x0F; nibble++ {
+		state.V[nibble] = *m.numToReg(nibble)
+	}
+	return state
+}
+
+func (m *machine) loadState(state machineState) error {
+	if len(state.Mem) != len(m.mem) {
+		return fmt.Errorf("saved memory is %d bytes, expected %d", len(state.Mem), len(m.mem))
+	}
+	if len(state.FB) != XRES*YRES/8 {
+		return fmt.Errorf("saved display is %d bytes, expected %d", len(state.FB), XRES*YRES/8)
+	}
+	if int(state.SP) > len(state.Stack) {
+		return fmt.Errorf("saved stack pointer %d is out of range", state.SP)
+	}
+	// an instruction is two bytes, both of which have to be in memory
+	if int(state.PC) > len(m.mem)-2 {
+		return fmt.Errorf("saved program counter %03X is out of range", state.PC)
+	}
+	if int(state.I) >= len(m.mem) {
+		return fmt.Errorf("saved I %03X is out of range", state.I)
+	}
+	m.cpuMutex.Lock()
+	defer m.cpuMutex.Unlock()
+	for nibble := byte(0); nibble <= 0x0F; nibble++ {
+		*m.numToReg(nibble) = state.V[nibble]
+	}
+	m.i = state.I
+	m.pc = state.PC
+	m.sp = state.SP
+	m.stack = state.Stack
+	*m.dt = state.DT
+	*m.st = state.ST
+	copy(m.mem[:], state.Mem)
+	m.rom = state.ROM
+
+	m.disp.Lock()
+	m.disp.fb = unpackFramebuffer(state.FB)
+	m.disp.updated = true
+	m.disp.Unlock()
+
+	m.kbMutex.Lock()
+	m.keyWait = keyWaitState{}
+	m.kbMutex.Unlock()
+	return nil
+}
+
+func (m *machine) saveStateFile(path string) error {
+	b, err := json.Marshal(m.saveState())
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+func (m *machine) loadStateFile(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var state machineState
+	if err := json.Unmarshal(b, &state); err != nil {
+		return fmt.Errorf("malformed state file %s: %v", path, err)
+	}
+	return m.loadState(state)
+}
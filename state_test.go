@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// a state that would leave the CPU reading outside memory is refused, and leaves the machine as it was
+func TestLoadStateRejectsOutOfRange(t *testing.T) {
+	m := newMachine()
+	good := m.saveState()
+	tests := []struct {
+		name   string
+		change func(*machineState)
+		want   string
+	}{
+		{"pc past memory", func(s *machineState) { s.PC = 0xFFFF }, "program counter FFFF"},
+		{"pc in the last byte", func(s *machineState) { s.PC = uint16(len(m.mem) - 1) }, fmt.Sprintf("program counter %03X", len(m.mem)-1)},
+		{"i past memory", func(s *machineState) { s.I = uint16(len(m.mem)) }, fmt.Sprintf("I %03X", len(m.mem))},
+		{"sp past the stack", func(s *machineState) { s.SP = 17 }, "stack pointer 17"},
+		{"short memory", func(s *machineState) { s.Mem = s.Mem[:100] }, "saved memory is 100 bytes"},
+	}
+	for _, test := range tests {
+		state := good
+		state.Mem = append([]byte(nil), good.Mem...)
+		test.change(&state)
+		err := m.loadState(state)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: loadState = %v, want an error containing %q", test.name, err, test.want)
+		}
+		if m.pc != good.PC || m.i != good.I {
+			t.Errorf("%s: the refused state was loaded", test.name)
+		}
+	}
+
+	edge := good
+	edge.PC, edge.I = uint16(len(m.mem)-2), uint16(len(m.mem)-1)
+	if err := m.loadState(edge); err != nil {
+		t.Errorf("loadState with pc and I at the end of memory: %v", err)
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"golang.org/x/term"
+	"image/color"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// terminals only report key presses, so a key counts as held until it stops repeating.
+	// a fresh press is held long enough to bridge the terminal's key repeat delay
+	TERM_KEY_PRESS_HOLD = 500 * time.Millisecond
+	// once a key is repeating it is released shortly after the repeats stop
+	TERM_KEY_REPEAT_HOLD = 100 * time.Millisecond
+)
+
+// one character cell of terminal output
+type termCell struct {
+	ch     rune
+	fg, bg color.RGBA
+}
+
+// termRenderer draws the framebuffer into a terminal with ANSI escapes, redrawing only the cells that changed
+type termRenderer struct {
+	w       io.Writer
+	braille bool
+	fg, bg  color.RGBA
+	prev    [][]termCell
+}
+
+func newTermRenderer(w io.Writer, braille bool) *termRenderer {
+	return &termRenderer{
+		w:       w,
+		braille: braille,
+	}
+}
+
+// braille dot bits, indexed by [y][x] within a 2x4 cell
+var brailleDots = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// converts the framebuffer to a grid of cells.  half blocks cover 1x2 pixels per cell, braille covers 2x4
+func (r *termRenderer) cells(fb framebuffer) [][]termCell {
+	var grid [][]termCell
+	if r.braille {
+		for cy := 0; cy < YRES/4; cy++ {
+			row := make([]termCell, XRES/2)
+			for cx := range row {
+				ch := rune(0x2800)
+				for dy := 0; dy < 4; dy++ {
+					for dx := 0; dx < 2; dx++ {
+						if fb[cx*2+dx][cy*4+dy] {
+							ch |= brailleDots[dy][dx]
+						}
+					}
+				}
+				row[cx] = termCell{ch: ch, fg: r.fg, bg: r.bg}
+			}
+			grid = append(grid, row)
+		}
+		return grid
+	}
+
+	for cy := 0; cy < YRES/2; cy++ {
+		row := make([]termCell, XRES)
+		for cx := range row {
+			// the upper half block takes the foreground color, the lower half the background
+			row[cx] = termCell{ch: '▀', fg: r.bg, bg: r.bg}
+			if fb[cx][cy*2] {
+				row[cx].fg = r.fg
+			}
+			if fb[cx][cy*2+1] {
+				row[cx].bg = r.fg
+			}
+		}
+		grid = append(grid, row)
+	}
+	return grid
+}
+
+// clears the terminal and forgets what was drawn, so the next render redraws every cell
+func (r *termRenderer) reset() {
+	r.prev = nil
+	fmt.Fprint(r.w, "\x1b[0m\x1b[2J\x1b[?25l")
+}
+
+// restores the terminal's cursor and colors
+func (r *termRenderer) close() {
+	fmt.Fprintf(r.w, "\x1b[0m\x1b[%d;1H\x1b[?25h\r\n", len(r.prev)+2)
+}
+
+// draws the cells that changed since the last render, followed by the status line
+func (r *termRenderer) render(fb framebuffer, status string) {
+	p := currentPalette()
+	r.fg, r.bg = p[1], p[0]
+	grid := r.cells(fb)
+	var buf bytes.Buffer
+	var lastFG, lastBG color.RGBA
+	colorsSet := false
+	for y, row := range grid {
+		cursorX := -1
+		for x, cell := range row {
+			if r.prev != nil && r.prev[y][x] == cell {
+				continue
+			}
+			// skip the cursor movement when writing consecutive cells
+			if cursorX != x {
+				fmt.Fprintf(&buf, "\x1b[%d;%dH", y+1, x+1)
+			}
+			if !colorsSet || cell.fg != lastFG || cell.bg != lastBG {
+				fmt.Fprintf(&buf, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm", cell.fg.R, cell.fg.G, cell.fg.B, cell.bg.R, cell.bg.G, cell.bg.B)
+				lastFG, lastBG, colorsSet = cell.fg, cell.bg, true
+			}
+			buf.WriteRune(cell.ch)
+			cursorX = x + 1
+		}
+	}
+	fmt.Fprintf(&buf, "\x1b[0m\x1b[%d;1H\x1b[K%s", len(grid)+1, status)
+	r.prev = grid
+	r.w.Write(buf.Bytes())
+}
+
+// termInput reads the hex keypad from a raw-mode terminal, emulating key releases with timeouts.
+// tab switches to the memory viewer, which gets the keys instead until tab is pressed again
+type termInput struct {
+	sync.Mutex
+	lastSeen   [16]time.Time
+	repeating  [16]bool
+	viewing    bool        // whether the memory viewer is up
+	memoryKeys chan []byte // input for the memory viewer, escape sequences and all
+	quit       chan struct{}
+}
+
+// starts reading keys from the given terminal.  the quit channel closes on ctrl-c or end of input
+func newTermInput(r io.Reader) *termInput {
+	in := &termInput{memoryKeys: make(chan []byte, 16), quit: make(chan struct{})}
+	go in.read(r)
+	return in
+}
+
+func (in *termInput) read(r io.Reader) {
+	defer close(in.quit)
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		for idx := 0; idx < n; idx++ {
+			b := buf[idx]
+			if b == 0x03 { // ctrl-c
+				return
+			}
+			if b == '\t' {
+				in.Lock()
+				in.viewing = !in.viewing
+				in.lastSeen = [16]time.Time{}
+				in.Unlock()
+				continue
+			}
+			if in.isViewingMemory() {
+				// everything up to the next tab or ctrl-c goes to the viewer in one piece
+				end := idx
+				for end < n && buf[end] != '\t' && buf[end] != 0x03 {
+					end++
+				}
+				in.memoryKeys <- append([]byte(nil), buf[idx:end]...)
+				idx = end - 1
+				continue
+			}
+			in.press(b)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (in *termInput) isViewingMemory() bool {
+	in.Lock()
+	defer in.Unlock()
+	return in.viewing
+}
+
+// records a key press (or repeat) for the hex key typed as the given character
+func (in *termInput) press(ch byte) {
+	nibble, ok := charToNibble(ch)
+	if !ok {
+		return
+	}
+	in.Lock()
+	defer in.Unlock()
+	now := time.Now()
+	in.repeating[nibble] = in.isHeld(nibble, now)
+	in.lastSeen[nibble] = now
+}
+
+func (in *termInput) isHeld(nibble byte, now time.Time) bool {
+	hold := TERM_KEY_PRESS_HOLD
+	if in.repeating[nibble] {
+		hold = TERM_KEY_REPEAT_HOLD
+	}
+	return now.Sub(in.lastSeen[nibble]) < hold
+}
+
+func (in *termInput) heldKeys() [16]bool {
+	in.Lock()
+	defer in.Unlock()
+	var held [16]bool
+	now := time.Now()
+	for nibble := range held {
+		held[nibble] = in.isHeld(byte(nibble), now)
+	}
+	return held
+}
+
+// runs the machine in the controlling terminal until ctrl-c is pressed
+func runTerminal(m *machine, braille bool) error {
+	fd := int(os.Stdin.Fd())
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("terminal frontend needs a tty: %v", err)
+	}
+	defer term.Restore(fd, oldState)
+
+	m.start()
+	defer m.stop()
+	runTerminalSession(m, os.Stdin, os.Stdout, braille)
+	return nil
+}
+
+// draws the running machine to a raw-mode terminal and feeds it keys until ctrl-c is pressed or the input ends
+func runTerminalSession(m *machine, in io.Reader, out io.Writer, braille bool) {
+	input := newTermInput(in)
+	m.kbMutex.Lock()
+	m.inputSources = append(m.inputSources, input)
+	m.kbMutex.Unlock()
+	screen := newTermRenderer(out, braille)
+	screen.reset()
+	defer screen.close()
+
+	frameTick := time.NewTicker(16667 * time.Microsecond)
+	defer frameTick.Stop()
+	second := time.NewTicker(time.Second)
+	defer second.Stop()
+	frames, fps := 0, 0
+	// the viewer keeps its place between openings, and takes keys sent before its first frame
+	view := &memoryView{}
+	viewShown := false
+	msg := ""
+	for {
+		select {
+		case <-input.quit:
+			return
+		case <-second.C:
+			fps = frames
+			frames = 0
+		case keys := <-input.memoryKeys:
+			msg = handleMemoryKeys(view, m, keys)
+		case <-frameTick.C:
+			if input.isViewingMemory() {
+				if !viewShown {
+					m.trackMemoryAccess()
+					screen.reset()
+					viewShown = true
+				}
+				renderMemoryView(out, view, m, msg)
+				continue
+			}
+			if viewShown {
+				screen.reset()
+				viewShown = false
+			}
+			status := m.achievements.toast()
+			if status == "" {
+				m.cpuMutex.Lock()
+				pc := m.pc
+				m.cpuMutex.Unlock()
+				status = fmt.Sprintf("gopotato | pc: 0x%03X | FPS: %d | tab for memory | ctrl-c to quit", pc, fps)
+			}
+			screen.render(m.disp.shown(), status)
+			frames++
+		}
+	}
+}
+
+// the terminal colours for each memory viewer highlight, as SGR parameters
+var memoryStyleSGR = map[int]string{
+	MEMORY_PLAIN:   "0",
+	MEMORY_READ:    "94",
+	MEMORY_WRITTEN: "91",
+	MEMORY_I:       "92",
+	MEMORY_PC:      "93",
+	MEMORY_CURSOR:  "7",
+}
+
+// redraws the memory viewer over the whole terminal
+func renderMemoryView(w io.Writer, v *memoryView, m *machine, msg string) {
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[H\x1b[0m")
+	fmt.Fprintf(&buf, "%s\x1b[K\r\n", v.header(m))
+	fmt.Fprint(&buf, "arrows/page up/down move, p pause, g go to pc, i go to I, tab back to the game\x1b[K\r\n")
+	for _, line := range v.lines(m) {
+		for _, span := range line {
+			fmt.Fprintf(&buf, "\x1b[%sm%s\x1b[0m", memoryStyleSGR[span.style], span.text)
+		}
+		buf.WriteString("\x1b[K\r\n")
+	}
+	fmt.Fprintf(&buf, "%s\x1b[K", msg)
+	w.Write(buf.Bytes())
+}
+
+// applies terminal input to the memory viewer, returning an error message to show, if any
+func handleMemoryKeys(v *memoryView, m *machine, keys []byte) string {
+	moves := map[string]int{
+		"\x1b[A": -MEMORY_VIEW_COLUMNS, "\x1b[B": MEMORY_VIEW_COLUMNS, "\x1b[C": 1, "\x1b[D": -1,
+		"\x1b[5~": -MEMORY_VIEW_COLUMNS * MEMORY_VIEW_ROWS, "\x1b[6~": MEMORY_VIEW_COLUMNS * MEMORY_VIEW_ROWS,
+	}
+	msg := ""
+	for len(keys) > 0 {
+		if keys[0] == 0x1b {
+			// an escape sequence runs to its final letter or ~
+			end := 1
+			for end < len(keys) && (end == 1 || !(keys[end] >= 0x40 && keys[end] <= 0x7E)) {
+				end++
+			}
+			if end < len(keys) {
+				end++
+			}
+			v.move(moves[string(keys[:end])])
+			keys = keys[end:]
+			continue
+		}
+		switch ch := keys[0]; ch {
+		case 'p', 'P':
+			m.setPaused(!m.isPaused())
+		case 'g', 'G':
+			v.jump(int(m.saveState().PC))
+		case 'i', 'I':
+			v.jump(int(m.saveState().I))
+		default:
+			if nibble, ok := charToNibble(ch); ok {
+				if err := v.typeNibble(m, nibble); err != nil {
+					msg = err.Error()
+				}
+			}
+		}
+		keys = keys[1:]
+	}
+	return msg
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"image"
+	"math"
+)
+
+// the image's pixels as OpenGL wants them for a texture, rows from the bottom up, reusing pix's storage
+func glPixels(img *image.RGBA, pix []uint8) []uint8 {
+	b := img.Bounds()
+	pix = pix[:0]
+	for y := b.Dy() - 1; y >= 0; y-- {
+		pix = append(pix, img.Pix[y*img.Stride:y*img.Stride+4*b.Dx()]...)
+	}
+	return pix
+}
+
+// how much each of a display of resolution res's pixels is stretched by to fit an area of the window: a whole
+// number, unless fractional or the area is too small for even 1x, which shrinks the display rather than hiding it
+func fitScale(width, height float64, res image.Point, fractional bool) float64 {
+	scale := math.Min(width/float64(res.X), height/float64(res.Y))
+	if !fractional && scale >= 1 {
+		scale = math.Floor(scale)
+	}
+	return scale
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"testing"
+)
+
+// the CPU's share of a window frame through each filter: turning a changed display into texture pixels.
+// stretching the texture to the window is left to the GPU, so this doesn't depend on the scale
+func BenchmarkScreen(b *testing.B) {
+	p := currentPalette()
+	for _, f := range filters {
+		b.Run(f.name, func(b *testing.B) {
+			var s shades
+			var pix []uint8
+			for n := 0; n < b.N; n++ {
+				// a pixel changes every frame, so the texture is redrawn every time as it is in play
+				s[n%XRES][n/XRES%YRES] = 1 - s[n%XRES][n/XRES%YRES]
+				pix = glPixels(f.run(s.image(p)), pix)
+			}
+		})
+	}
+}
+
+// a frame drawn at the window's size on the CPU, at the default SCALE and at double it, as the renderer did
+// before the texture.  to compare against BenchmarkScreen, whose cost stays put as this one grows
+func BenchmarkWindowSizedFrame(b *testing.B) {
+	for _, scale := range []int{SCALE, SCALE * 2} {
+		b.Run(fmt.Sprintf("scale%d", scale), func(b *testing.B) {
+			var fb framebuffer
+			for n := 0; n < b.N; n++ {
+				fb[n%XRES][n/XRES%YRES] = !fb[n%XRES][n/XRES%YRES]
+				fb.image(scale)
+			}
+		})
+	}
+}
+
+// the same window fits a 128x64 display at half the scale of a 64x32 one
+func TestFitScale(t *testing.T) {
+	tests := []struct {
+		width, height float64
+		res           image.Point
+		fractional    bool
+		want          float64
+	}{
+		{640, 320, image.Pt(64, 32), false, 10},
+		{640, 320, image.Pt(128, 64), false, 5},
+		{700, 330, image.Pt(64, 32), false, 10},
+		{700, 330, image.Pt(128, 64), false, 5},
+		{700, 330, image.Pt(128, 64), true, 330.0 / 64},
+		{100, 40, image.Pt(128, 64), false, 40.0 / 64},
+	}
+	for _, test := range tests {
+		if got := fitScale(test.width, test.height, test.res, test.fractional); got != test.want {
+			t.Errorf("fitScale(%v, %v, %v, %v) = %v, want %v", test.width, test.height, test.res, test.fractional, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,508 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// RFB encodings, client to server message types and hextile sub-encodings
+const (
+	RFB_ENCODING_RAW     = 0
+	RFB_ENCODING_RRE     = 2
+	RFB_ENCODING_HEXTILE = 5
+
+	RFB_SET_PIXEL_FORMAT = 0
+	RFB_SET_ENCODINGS    = 2
+	RFB_UPDATE_REQUEST   = 3
+	RFB_KEY_EVENT        = 4
+	RFB_POINTER_EVENT    = 5
+	RFB_CLIENT_CUT_TEXT  = 6
+
+	HEXTILE_BACKGROUND   = 0x02
+	HEXTILE_FOREGROUND   = 0x04
+	HEXTILE_ANY_SUBRECTS = 0x08
+)
+
+// how a viewer wants pixels packed, as sent in ServerInit and SetPixelFormat
+type rfbPixelFormat struct {
+	BPP, Depth, BigEndian, TrueColor uint8
+	RedMax, GreenMax, BlueMax        uint16
+	RedShift, GreenShift, BlueShift  uint8
+	_                                [3]byte
+}
+
+// 32 bit little-endian xRGB, which every viewer can take
+var rfbDefaultPixelFormat = rfbPixelFormat{
+	BPP: 32, Depth: 24, TrueColor: 1,
+	RedMax: 255, GreenMax: 255, BlueMax: 255,
+	RedShift: 16, GreenShift: 8, BlueShift: 0,
+}
+
+// packs a palette colour in this format.  colour-mapped viewers are given the palette as their map
+func (pf rfbPixelFormat) pixel(p palette, idx int) []byte {
+	var v uint32
+	if pf.TrueColor == 0 {
+		v = uint32(idx)
+	} else {
+		c := p[idx]
+		v = uint32(c.R)*uint32(pf.RedMax)/0xFF<<pf.RedShift |
+			uint32(c.G)*uint32(pf.GreenMax)/0xFF<<pf.GreenShift |
+			uint32(c.B)*uint32(pf.BlueMax)/0xFF<<pf.BlueShift
+	}
+	b := make([]byte, 4)
+	if pf.BigEndian != 0 {
+		binary.BigEndian.PutUint32(b, v)
+		return b[4-pf.BPP/8:]
+	}
+	binary.LittleEndian.PutUint32(b, v)
+	return b[:pf.BPP/8]
+}
+
+// a rectangle of the scaled display
+type rfbRect struct {
+	x, y, w, h int
+}
+
+// vncServer serves the display over RFB 3.8 to any number of viewers, all of whom can press keys
+type vncServer struct {
+	sync.Mutex
+	m       *machine
+	scale   int
+	viewers map[*vncViewer]bool
+}
+
+type vncViewer struct {
+	conn     net.Conn
+	r        *bufio.Reader
+	w        *bufio.Writer
+	pf       rfbPixelFormat
+	encoding int32
+	keypad   virtualKeypad
+	last     framebuffer
+	sentAny  bool
+}
+
+// a message from a viewer that changes what the writer sends
+type vncEvent struct {
+	pf          *rfbPixelFormat
+	encodings   []int32
+	update      *rfbRect
+	incremental bool
+}
+
+func vncCommand(args []string) error {
+	fs := flag.NewFlagSet("vnc", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gopotato vnc [flags] [rom.ch8]\n")
+		fs.PrintDefaults()
+	}
+	addr := fs.String("addr", ":5900", "address to listen for VNC viewers on")
+	scale := fs.Int("scale", SCALE, "size of each CHIP-8 pixel, in screen pixels")
+	machineFlags(fs)
+	fs.Parse(args)
+	m, err := loadGame(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *scale < 1 {
+		return fmt.Errorf("scale must be at least 1")
+	}
+
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	s := &vncServer{m: m, scale: *scale, viewers: map[*vncViewer]bool{}}
+	m.inputSources = []inputSource{s}
+	m.start()
+	fmt.Printf("serving VNC on %s\n", l.Addr())
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serve(conn)
+	}
+}
+
+// merges the keys held by every viewer
+func (s *vncServer) heldKeys() [16]bool {
+	s.Lock()
+	defer s.Unlock()
+	var held [16]bool
+	for v := range s.viewers {
+		for nibble, down := range v.keypad.heldKeys() {
+			held[nibble] = held[nibble] || down
+		}
+	}
+	return held
+}
+
+func (s *vncServer) serve(conn net.Conn) {
+	defer conn.Close()
+	v := &vncViewer{
+		conn:     conn,
+		r:        bufio.NewReader(conn),
+		w:        bufio.NewWriter(conn),
+		pf:       rfbDefaultPixelFormat,
+		encoding: RFB_ENCODING_RAW,
+	}
+	if err := v.handshake(XRES*s.scale, YRES*s.scale); err != nil {
+		return
+	}
+	s.Lock()
+	s.viewers[v] = true
+	s.Unlock()
+	defer func() {
+		s.Lock()
+		delete(s.viewers, v)
+		s.Unlock()
+	}()
+
+	events := make(chan vncEvent)
+	done := make(chan struct{})
+	defer close(done)
+	go v.read(events, done)
+	frameTick := time.NewTicker(16667 * time.Microsecond)
+	defer frameTick.Stop()
+	var pending *vncEvent
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			switch {
+			case ev.pf != nil:
+				v.pf = *ev.pf
+				if v.pf.TrueColor == 0 {
+					if err := v.sendColourMap(); err != nil {
+						return
+					}
+				}
+			case ev.encodings != nil:
+				v.encoding = RFB_ENCODING_RAW
+				for _, enc := range ev.encodings {
+					if enc == RFB_ENCODING_HEXTILE || enc == RFB_ENCODING_RRE {
+						v.encoding = enc
+						break
+					}
+				}
+			case ev.update != nil:
+				pending = &ev
+			}
+		case <-frameTick.C:
+		}
+
+		if pending == nil {
+			continue
+		}
+		fb := s.m.disp.shown()
+		rect := *pending.update
+		if pending.incremental && v.sentAny {
+			changed, ok := changedRect(v.last, fb, s.scale)
+			if !ok {
+				continue
+			}
+			if rect, ok = intersectRect(rect, changed); !ok {
+				continue
+			}
+		}
+		if err := v.sendUpdate(fb, rect, s.scale); err != nil {
+			return
+		}
+		v.last, v.sentAny, pending = fb, true, nil
+	}
+}
+
+// negotiates the protocol version and security, then exchanges the init messages
+func (v *vncViewer) handshake(width, height int) error {
+	if _, err := io.WriteString(v.conn, "RFB 003.008\n"); err != nil {
+		return err
+	}
+	version := make([]byte, 12)
+	if _, err := io.ReadFull(v.r, version); err != nil {
+		return err
+	}
+	var major, minor int
+	if _, err := fmt.Sscanf(string(version), "RFB %03d.%03d\n", &major, &minor); err != nil || major != 3 {
+		return fmt.Errorf("unsupported protocol version %q", version)
+	}
+
+	// only the None security type is offered.  3.3 viewers are told rather than asked
+	if minor < 7 {
+		binary.Write(v.w, binary.BigEndian, uint32(1))
+	} else {
+		v.w.Write([]byte{1, 1})
+		if err := v.w.Flush(); err != nil {
+			return err
+		}
+		secType, err := v.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if secType != 1 {
+			return fmt.Errorf("unsupported security type %d", secType)
+		}
+		if minor >= 8 {
+			binary.Write(v.w, binary.BigEndian, uint32(0))
+		}
+	}
+	if err := v.w.Flush(); err != nil {
+		return err
+	}
+
+	// the shared flag is ignored, every connection is shared
+	if _, err := v.r.ReadByte(); err != nil {
+		return err
+	}
+	name := "gopotato"
+	binary.Write(v.w, binary.BigEndian, uint16(width))
+	binary.Write(v.w, binary.BigEndian, uint16(height))
+	binary.Write(v.w, binary.BigEndian, v.pf)
+	binary.Write(v.w, binary.BigEndian, uint32(len(name)))
+	v.w.WriteString(name)
+	return v.w.Flush()
+}
+
+// parses messages from the viewer, handling key events itself and passing the rest to the writer
+func (v *vncViewer) read(events chan<- vncEvent, done <-chan struct{}) {
+	defer close(events)
+	defer v.keypad.releaseAll()
+	emit := func(ev vncEvent) bool {
+		select {
+		case events <- ev:
+			return true
+		case <-done:
+			return false
+		}
+	}
+	for {
+		msgType, err := v.r.ReadByte()
+		if err != nil {
+			return
+		}
+		switch msgType {
+		case RFB_SET_PIXEL_FORMAT:
+			var msg struct {
+				_  [3]byte
+				PF rfbPixelFormat
+			}
+			if binary.Read(v.r, binary.BigEndian, &msg) != nil {
+				return
+			}
+			if bpp := msg.PF.BPP; bpp != 8 && bpp != 16 && bpp != 32 {
+				return
+			}
+			if !emit(vncEvent{pf: &msg.PF}) {
+				return
+			}
+		case RFB_SET_ENCODINGS:
+			var msg struct {
+				_     byte
+				Count uint16
+			}
+			if binary.Read(v.r, binary.BigEndian, &msg) != nil {
+				return
+			}
+			encodings := make([]int32, msg.Count)
+			if binary.Read(v.r, binary.BigEndian, encodings) != nil {
+				return
+			}
+			if !emit(vncEvent{encodings: encodings}) {
+				return
+			}
+		case RFB_UPDATE_REQUEST:
+			var msg struct {
+				Incremental uint8
+				X, Y, W, H  uint16
+			}
+			if binary.Read(v.r, binary.BigEndian, &msg) != nil {
+				return
+			}
+			rect := rfbRect{int(msg.X), int(msg.Y), int(msg.W), int(msg.H)}
+			if !emit(vncEvent{update: &rect, incremental: msg.Incremental != 0}) {
+				return
+			}
+		case RFB_KEY_EVENT:
+			var msg struct {
+				Down uint8
+				_    [2]byte
+				Key  uint32
+			}
+			if binary.Read(v.r, binary.BigEndian, &msg) != nil {
+				return
+			}
+			// keysyms for 0-9, A-F and a-f match their ASCII codes
+			if msg.Key < 0x80 {
+				if nibble, ok := charToNibble(byte(msg.Key)); ok {
+					v.keypad.set(nibble, msg.Down != 0)
+				}
+			}
+		case RFB_POINTER_EVENT:
+			if _, err := v.r.Discard(5); err != nil {
+				return
+			}
+		case RFB_CLIENT_CUT_TEXT:
+			var msg struct {
+				_      [3]byte
+				Length uint32
+			}
+			if binary.Read(v.r, binary.BigEndian, &msg) != nil {
+				return
+			}
+			if _, err := v.r.Discard(int(msg.Length)); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// gives colour-mapped viewers the palette
+func (v *vncViewer) sendColourMap() error {
+	v.w.Write([]byte{1, 0})
+	p := currentPalette()
+	binary.Write(v.w, binary.BigEndian, []uint16{0, uint16(len(p))})
+	for _, c := range p {
+		binary.Write(v.w, binary.BigEndian, []uint16{uint16(c.R) * 0x101, uint16(c.G) * 0x101, uint16(c.B) * 0x101})
+	}
+	return v.w.Flush()
+}
+
+// sends one rectangle of the scaled display in the viewer's preferred encoding
+func (v *vncViewer) sendUpdate(fb framebuffer, rect rfbRect, scale int) error {
+	rect, ok := intersectRect(rect, rfbRect{0, 0, XRES * scale, YRES * scale})
+	if !ok {
+		rect = rfbRect{}
+	}
+	lit := func(x, y int) bool {
+		return fb[x/scale][y/scale]
+	}
+
+	v.w.Write([]byte{0, 0})
+	binary.Write(v.w, binary.BigEndian, uint16(1))
+	binary.Write(v.w, binary.BigEndian, []uint16{uint16(rect.x), uint16(rect.y), uint16(rect.w), uint16(rect.h)})
+	binary.Write(v.w, binary.BigEndian, v.encoding)
+	p := currentPalette()
+	on, off := v.pf.pixel(p, 1), v.pf.pixel(p, 0)
+	switch v.encoding {
+	case RFB_ENCODING_RRE:
+		subrects := litRects(lit, rect)
+		binary.Write(v.w, binary.BigEndian, uint32(len(subrects)))
+		v.w.Write(off)
+		for _, sub := range subrects {
+			v.w.Write(on)
+			binary.Write(v.w, binary.BigEndian, []uint16{uint16(sub.x - rect.x), uint16(sub.y - rect.y), uint16(sub.w), uint16(sub.h)})
+		}
+	case RFB_ENCODING_HEXTILE:
+		for ty := rect.y; ty < rect.y+rect.h; ty += 16 {
+			for tx := rect.x; tx < rect.x+rect.w; tx += 16 {
+				tile := rfbRect{tx, ty, minInt(16, rect.x+rect.w-tx), minInt(16, rect.y+rect.h-ty)}
+				subrects := litRects(lit, tile)
+				if len(subrects) == 0 {
+					v.w.WriteByte(HEXTILE_BACKGROUND)
+					v.w.Write(off)
+					continue
+				}
+				v.w.WriteByte(HEXTILE_BACKGROUND | HEXTILE_FOREGROUND | HEXTILE_ANY_SUBRECTS)
+				v.w.Write(off)
+				v.w.Write(on)
+				v.w.WriteByte(byte(len(subrects)))
+				for _, sub := range subrects {
+					v.w.WriteByte(byte((sub.x-tile.x)<<4 | (sub.y - tile.y)))
+					v.w.WriteByte(byte((sub.w-1)<<4 | (sub.h - 1)))
+				}
+			}
+		}
+	default:
+		for y := rect.y; y < rect.y+rect.h; y++ {
+			for x := rect.x; x < rect.x+rect.w; x++ {
+				if lit(x, y) {
+					v.w.Write(on)
+				} else {
+					v.w.Write(off)
+				}
+			}
+		}
+	}
+	return v.w.Flush()
+}
+
+// covers the lit pixels within the rectangle with rectangles, merging identical runs on consecutive rows
+func litRects(lit func(x, y int) bool, rect rfbRect) []rfbRect {
+	var rects []rfbRect
+	open := map[[2]int]int{} // run start and width to the index of the rectangle it extends
+	for y := rect.y; y < rect.y+rect.h; y++ {
+		nextOpen := map[[2]int]int{}
+		for x := rect.x; x < rect.x+rect.w; x++ {
+			if !lit(x, y) {
+				continue
+			}
+			start := x
+			for x < rect.x+rect.w && lit(x, y) {
+				x++
+			}
+			run := [2]int{start, x - start}
+			if idx, ok := open[run]; ok {
+				rects[idx].h++
+				nextOpen[run] = idx
+			} else {
+				nextOpen[run] = len(rects)
+				rects = append(rects, rfbRect{start, y, x - start, 1})
+			}
+		}
+		open = nextOpen
+	}
+	return rects
+}
+
+// the smallest rectangle of the scaled display covering every pixel that differs between the framebuffers
+func changedRect(a, b framebuffer, scale int) (rfbRect, bool) {
+	minX, minY, maxX, maxY := XRES, YRES, -1, -1
+	for x := 0; x < XRES; x++ {
+		for y := 0; y < YRES; y++ {
+			if a[x][y] != b[x][y] {
+				minX, maxX = minInt(minX, x), maxInt(maxX, x)
+				minY, maxY = minInt(minY, y), maxInt(maxY, y)
+			}
+		}
+	}
+	if maxX < 0 {
+		return rfbRect{}, false
+	}
+	return rfbRect{minX * scale, minY * scale, (maxX - minX + 1) * scale, (maxY - minY + 1) * scale}, true
+}
+
+func intersectRect(a, b rfbRect) (rfbRect, bool) {
+	x0, y0 := maxInt(a.x, b.x), maxInt(a.y, b.y)
+	x1, y1 := minInt(a.x+a.w, b.x+b.w), minInt(a.y+a.h, b.y+b.h)
+	if x1 <= x0 || y1 <= y0 {
+		return rfbRect{}, false
+	}
+	return rfbRect{x0, y0, x1 - x0, y1 - y0}, true
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,233 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// a minimal RFB 3.8 viewer, just enough to check what the server sends
+type rfbClient struct {
+	t      *testing.T
+	conn   net.Conn
+	r      *bufio.Reader
+	width  int
+	height int
+	pf     rfbPixelFormat
+}
+
+func (c *rfbClient) read(data interface{}) {
+	c.t.Helper()
+	if err := binary.Read(c.r, binary.BigEndian, data); err != nil {
+		c.t.Fatalf("reading from the server: %v", err)
+	}
+}
+
+func (c *rfbClient) write(data ...interface{}) {
+	c.t.Helper()
+	for _, d := range data {
+		if err := binary.Write(c.conn, binary.BigEndian, d); err != nil {
+			c.t.Fatalf("writing to the server: %v", err)
+		}
+	}
+}
+
+// connects to a server over a pipe and runs the 3.8 handshake, checking each step
+func dialVNC(t *testing.T, s *vncServer) *rfbClient {
+	server, client := net.Pipe()
+	go s.serve(server)
+	t.Cleanup(func() { client.Close() })
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	c := &rfbClient{t: t, conn: client, r: bufio.NewReader(client)}
+
+	version := make([]byte, 12)
+	if _, err := io.ReadFull(c.r, version); err != nil {
+		t.Fatal(err)
+	}
+	if string(version) != "RFB 003.008\n" {
+		t.Fatalf("server offered %q", version)
+	}
+	c.write([]byte("RFB 003.008\n"))
+	var count uint8
+	c.read(&count)
+	secTypes := make([]byte, count)
+	c.read(secTypes)
+	if string(secTypes) != "\x01" {
+		t.Fatalf("server offered security types %v, want only None", secTypes)
+	}
+	c.write(uint8(1))
+	var result uint32
+	c.read(&result)
+	if result != 0 {
+		t.Fatalf("security result %d", result)
+	}
+
+	c.write(uint8(1)) // shared
+	var init struct {
+		W, H uint16
+		PF   rfbPixelFormat
+		Len  uint32
+	}
+	c.read(&init)
+	name := make([]byte, init.Len)
+	c.read(name)
+	if string(name) != "gopotato" {
+		t.Errorf("desktop name %q", name)
+	}
+	c.width, c.height, c.pf = int(init.W), int(init.H), init.PF
+	return c
+}
+
+// asks for a rectangle of the display in the encoding and decodes the reply, as rows of whether each pixel is lit
+func (c *rfbClient) update(encoding int32, rect rfbRect) [][]bool {
+	c.t.Helper()
+	c.write(uint8(RFB_SET_ENCODINGS), uint8(0), uint16(1), encoding)
+	c.write(uint8(RFB_UPDATE_REQUEST), uint8(0), []uint16{uint16(rect.x), uint16(rect.y), uint16(rect.w), uint16(rect.h)})
+
+	var header struct {
+		Type, _ uint8
+		Rects   uint16
+	}
+	c.read(&header)
+	if header.Type != 0 || header.Rects != 1 {
+		c.t.Fatalf("got message type %d with %d rectangles, want one update rectangle", header.Type, header.Rects)
+	}
+	var r struct {
+		X, Y, W, H uint16
+		Encoding   int32
+	}
+	c.read(&r)
+	if got := (rfbRect{int(r.X), int(r.Y), int(r.W), int(r.H)}); got != rect || r.Encoding != encoding {
+		c.t.Fatalf("got %v in encoding %d, want %v in %d", got, r.Encoding, rect, encoding)
+	}
+
+	pixels := make([][]bool, rect.h)
+	for y := range pixels {
+		pixels[y] = make([]bool, rect.w)
+	}
+	fill := func(x, y, w, h int, lit bool) {
+		for dy := 0; dy < h; dy++ {
+			for dx := 0; dx < w; dx++ {
+				pixels[y+dy][x+dx] = lit
+			}
+		}
+	}
+	bpp := int(c.pf.BPP / 8)
+	on := c.pf.pixel(currentPalette(), 1)
+	pixel := func() bool {
+		b := make([]byte, bpp)
+		c.read(b)
+		return string(b) == string(on)
+	}
+
+	switch encoding {
+	case RFB_ENCODING_RAW:
+		for y := 0; y < rect.h; y++ {
+			for x := 0; x < rect.w; x++ {
+				pixels[y][x] = pixel()
+			}
+		}
+	case RFB_ENCODING_RRE:
+		var subrects uint32
+		c.read(&subrects)
+		fill(0, 0, rect.w, rect.h, pixel())
+		for n := uint32(0); n < subrects; n++ {
+			lit := pixel()
+			var sub [4]uint16
+			c.read(&sub)
+			fill(int(sub[0]), int(sub[1]), int(sub[2]), int(sub[3]), lit)
+		}
+	case RFB_ENCODING_HEXTILE:
+		var bg, fg bool
+		for ty := 0; ty < rect.h; ty += 16 {
+			for tx := 0; tx < rect.w; tx += 16 {
+				w, h := minInt(16, rect.w-tx), minInt(16, rect.h-ty)
+				var flags uint8
+				c.read(&flags)
+				if flags&0x01 != 0 {
+					c.t.Fatal("raw hextile tiles aren't expected")
+				}
+				if flags&HEXTILE_BACKGROUND != 0 {
+					bg = pixel()
+				}
+				if flags&HEXTILE_FOREGROUND != 0 {
+					fg = pixel()
+				}
+				fill(tx, ty, w, h, bg)
+				if flags&HEXTILE_ANY_SUBRECTS == 0 {
+					continue
+				}
+				var count uint8
+				c.read(&count)
+				for n := uint8(0); n < count; n++ {
+					lit := fg
+					if flags&0x10 != 0 {
+						lit = pixel()
+					}
+					var xy, wh uint8
+					c.read(&xy)
+					c.read(&wh)
+					fill(tx+int(xy>>4), ty+int(xy&0x0F), int(wh>>4)+1, int(wh&0x0F)+1, lit)
+				}
+			}
+		}
+	}
+	return pixels
+}
+
+func TestVNC(t *testing.T) {
+	setTheme(themes[0])
+	const scale = 3
+	m := newMachine()
+	// a diagonal, a block and a lone pixel in the corner, which land across tile edges at this scale
+	m.disp.Lock()
+	for n := 0; n < YRES; n++ {
+		m.disp.fb[n][n] = true
+	}
+	for x := 20; x < 30; x++ {
+		for y := 4; y < 9; y++ {
+			m.disp.fb[x][y] = true
+		}
+	}
+	m.disp.fb[XRES-1][YRES-1] = true
+	m.disp.Unlock()
+	s := &vncServer{m: m, scale: scale, viewers: map[*vncViewer]bool{}}
+	m.inputSources = []inputSource{s}
+
+	c := dialVNC(t, s)
+	if c.width != XRES*scale || c.height != YRES*scale {
+		t.Fatalf("server is %dx%d, want %dx%d", c.width, c.height, XRES*scale, YRES*scale)
+	}
+	for _, encoding := range []int32{RFB_ENCODING_RAW, RFB_ENCODING_RRE, RFB_ENCODING_HEXTILE} {
+		for _, rect := range []rfbRect{{0, 0, XRES * scale, YRES * scale}, {50, 7, 61, 40}} {
+			pixels := c.update(encoding, rect)
+			for y := range pixels {
+				for x, lit := range pixels[y] {
+					if want := m.disp.fb[(rect.x+x)/scale][(rect.y+y)/scale]; lit != want {
+						t.Fatalf("encoding %d, %v: pixel %d,%d is lit %v, want %v", encoding, rect, rect.x+x, rect.y+y, lit, want)
+					}
+				}
+			}
+		}
+	}
+
+	// key events press the keypad, as the next update shows they've been read
+	c.write(uint8(RFB_KEY_EVENT), uint8(1), uint16(0), uint32('a'))
+	c.update(RFB_ENCODING_RAW, rfbRect{0, 0, 1, 1})
+	m.pollForKeys()
+	if held := m.heldKeys(); !held[0xA] {
+		t.Errorf("A isn't held after a key down: %v", held)
+	}
+	c.write(uint8(RFB_KEY_EVENT), uint8(0), uint16(0), uint32('a'))
+	c.update(RFB_ENCODING_RAW, rfbRect{0, 0, 1, 1})
+	m.pollForKeys()
+	if held := m.heldKeys(); held[0xA] {
+		t.Errorf("A is still held after a key up: %v", held)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"syscall/js"
+)
+
+const BUZZER_VOLUME = 0.1
+
+// buzzer plays a square wave through WebAudio while the sound timer is running
+type buzzer struct {
+	started bool
+	ctx     js.Value
+	gain    js.Value
+}
+
+// browsers only allow audio to start from a user gesture, so this is called from input handlers
+func (b *buzzer) start() {
+	if b.started {
+		return
+	}
+	ctor := js.Global().Get("AudioContext")
+	if ctor.IsUndefined() {
+		ctor = js.Global().Get("webkitAudioContext")
+	}
+	if ctor.IsUndefined() {
+		return
+	}
+	b.ctx = ctor.New()
+	osc := b.ctx.Call("createOscillator")
+	osc.Set("type", "square")
+	osc.Get("frequency").Set("value", BUZZER_HZ)
+	b.gain = b.ctx.Call("createGain")
+	b.gain.Get("gain").Set("value", 0)
+	osc.Call("connect", b.gain)
+	b.gain.Call("connect", b.ctx.Get("destination"))
+	osc.Call("start")
+	b.started = true
+}
+
+func (b *buzzer) set(on bool) {
+	if !b.started {
+		return
+	}
+	volume := 0.0
+	if on {
+		volume = BUZZER_VOLUME
+	}
+	b.gain.Get("gain").Call("setValueAtTime", volume, b.ctx.Get("currentTime"))
+}
+
+var (
+	doc       = js.Global().Get("document")
+	startOnce sync.Once
+)
+
+// shows a message beneath the canvas
+func setStatus(msg string) {
+	doc.Call("getElementById", "status").Set("textContent", msg)
+}
+
+// resets the machine and runs the ROM held in the given ArrayBuffer
+func startROM(m *machine, buf js.Value) {
+	data := js.Global().Get("Uint8Array").New(buf)
+	rom := make([]byte, data.Length())
+	js.CopyBytesToGo(rom, data)
+	m.load(rom)
+	startOnce.Do(m.start)
+	setStatus(fmt.Sprintf("loaded %d byte ROM", len(rom)))
+}
+
+func main() {
+	canvas := doc.Call("getElementById", "screen")
+	canvas.Set("width", XRES)
+	canvas.Set("height", YRES)
+	ctx2d := canvas.Call("getContext", "2d")
+	img := ctx2d.Call("createImageData", XRES, YRES)
+	pixels := make([]byte, XRES*YRES*4)
+
+	m := newMachine()
+	input := &virtualKeypad{}
+	m.inputSources = []inputSource{input}
+	sound := &buzzer{}
+
+	onKey := func(down bool) js.Func {
+		return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			e := args[0]
+			key := e.Get("key").String()
+			if len(key) != 1 {
+				return nil
+			}
+			if nibble, ok := charToNibble(key[0]); ok {
+				e.Call("preventDefault")
+				input.set(nibble, down)
+				sound.start()
+			}
+			return nil
+		})
+	}
+	doc.Call("addEventListener", "keydown", onKey(true))
+	doc.Call("addEventListener", "keyup", onKey(false))
+
+	// the on-page keypad's buttons carry their hex key in a data-key attribute
+	buttons := doc.Call("querySelectorAll", "[data-key]")
+	for idx := 0; idx < buttons.Length(); idx++ {
+		btn := buttons.Index(idx)
+		nibble, ok := charToNibble(btn.Get("dataset").Get("key").String()[0])
+		if !ok {
+			continue
+		}
+		handler := func(down bool) js.Func {
+			return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+				args[0].Call("preventDefault")
+				input.set(nibble, down)
+				sound.start()
+				return nil
+			})
+		}
+		for _, ev := range []string{"touchstart", "mousedown"} {
+			btn.Call("addEventListener", ev, handler(true))
+		}
+		for _, ev := range []string{"touchend", "touchcancel", "mouseup", "mouseleave"} {
+			btn.Call("addEventListener", ev, handler(false))
+		}
+	}
+
+	doc.Call("getElementById", "rom").Call("addEventListener", "change", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		files := this.Get("files")
+		if files.Length() == 0 {
+			return nil
+		}
+		sound.start()
+		files.Index(0).Call("arrayBuffer").Call("then", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			startROM(m, args[0])
+			return nil
+		}))
+		return nil
+	}))
+
+	// a ?rom=games/pong.ch8 URL parameter fetches a ROM from the web server
+	params := js.Global().Get("URLSearchParams").New(js.Global().Get("location").Get("search"))
+	if romURL := params.Call("get", "rom"); !romURL.IsNull() {
+		setStatus("fetching " + romURL.String())
+		js.Global().Call("fetch", romURL).Call("then", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			resp := args[0]
+			if !resp.Get("ok").Bool() {
+				setStatus(fmt.Sprintf("failed to fetch %s: %d %s", romURL.String(), resp.Get("status").Int(), resp.Get("statusText").String()))
+				return nil
+			}
+			return resp.Call("arrayBuffer").Call("then", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+				startROM(m, args[0])
+				return nil
+			}))
+		}))
+	}
+
+	buzzing := false
+	var frame js.Func
+	frame = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		s := m.disp.shades()
+		p := currentPalette()
+		for y := 0; y < YRES; y++ {
+			for x := 0; x < XRES; x++ {
+				c := s.color(p, x, y)
+				idx := (y*XRES + x) * 4
+				pixels[idx], pixels[idx+1], pixels[idx+2], pixels[idx+3] = c.R, c.G, c.B, 0xFF
+			}
+		}
+		js.CopyBytesToJS(img.Get("data"), pixels)
+		ctx2d.Call("putImageData", img, 0, 0)
+
+		if (*m.st != 0x00) != buzzing {
+			buzzing = !buzzing
+			sound.set(buzzing)
+		}
+		js.Global().Call("requestAnimationFrame", frame)
+		return nil
+	})
+	js.Global().Call("requestAnimationFrame", frame)
+
+	select {}
+}
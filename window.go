@@ -0,0 +1,140 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/pixelgl"
+	"image"
+	"math"
+)
+
+var window *pixelgl.Window
+
+// whether the display fills as much of the window as it can, rather than being scaled by a whole number
+var fractionalScale bool
+
+func initDisp() {
+	cfg := pixelgl.WindowConfig{
+		Title:     "gopotato",
+		Bounds:    pixel.R(0, 0, XRES*SCALE, YRES*SCALE),
+		VSync:     true,
+		Resizable: true,
+	}
+	win, err := pixelgl.NewWindow(cfg)
+	if err != nil {
+		panic(err)
+	}
+	// nearest neighbour, so the display's pixels stay sharp when stretched
+	win.SetSmooth(false)
+	win.Clear(currentPalette()[0])
+	window = win
+}
+
+// the display as a texture, redrawn when what's shown changes and stretched over the window each frame
+type screen struct {
+	canvas *pixelgl.Canvas
+	pix    []uint8 // the filtered image, rows bottom up as OpenGL wants them
+	factor int     // how much the filter scaled the display up by
+	// what the canvas holds
+	drawn     shades
+	drawnIn   palette
+	drawnWith string
+	valid     bool
+	res       image.Point // the display's resolution, as last drawn
+
+	reserved float64 // window width kept clear down the right for a side panel
+}
+
+func newScreen() *screen {
+	return &screen{canvas: pixelgl.NewCanvas(pixel.R(0, 0, XRES, YRES)), factor: 1, res: image.Pt(XRES, YRES)}
+}
+
+// draws the display as large as fits in the middle of the window, left of any reserved width, letterboxed in the background colour.
+// the caller updates the window
+func (scr *screen) draw(disp *display) {
+	s := disp.shades()
+	p := currentPalette()
+	f := currentFilter()
+	if !scr.valid || s != scr.drawn || p != scr.drawnIn || f.name != scr.drawnWith {
+		unfiltered := s.image(p)
+		img := f.run(unfiltered)
+		b := img.Bounds()
+		if scr.canvas.Bounds().W() != float64(b.Dx()) || scr.canvas.Bounds().H() != float64(b.Dy()) {
+			scr.canvas.SetBounds(pixel.R(0, 0, float64(b.Dx()), float64(b.Dy())))
+		}
+		scr.pix = glPixels(img, scr.pix)
+		scr.canvas.SetPixels(scr.pix)
+		scr.factor = f.factor
+		scr.res = unfiltered.Bounds().Size()
+		scr.drawn, scr.drawnIn, scr.drawnWith, scr.valid = s, p, f.name, true
+	}
+	window.Clear(p[0])
+	scr.canvas.Draw(window, pixel.IM.Scaled(pixel.ZV, scr.scale()/float64(scr.factor)).Moved(scr.bounds().Center()))
+}
+
+// the part of the window the display is laid out in: all of it but the reserved width, which gives way
+// rather than leave no room for the display at 1x
+func (scr *screen) bounds() pixel.Rect {
+	b := window.Bounds()
+	b.Max.X = math.Max(b.Min.X+float64(scr.res.X), b.Max.X-scr.reserved)
+	return b
+}
+
+// how much each of the display's pixels is stretched by to fit its part of the window.  laid out again every frame,
+// so it follows the window's size, the side panel and the display's resolution
+func (scr *screen) scale() float64 {
+	b := scr.bounds()
+	return fitScale(b.W(), b.H(), scr.res, fractionalScale)
+}
+
+// the window area the display covers, as drawn by the last draw
+func (scr *screen) area() pixel.Rect {
+	scale := scr.scale()
+	size := pixel.V(float64(scr.res.X)*scale, float64(scr.res.Y)*scale)
+	min := scr.bounds().Center().Sub(size.Scaled(0.5))
+	return pixel.Rect{Min: min, Max: min.Add(size)}
+}
+
+// F11 switches between the window and fullscreen on the primary monitor, in the monitor's current video mode
+func toggleFullscreen(win *pixelgl.Window) {
+	if win.Monitor() != nil {
+		win.SetMonitor(nil)
+		return
+	}
+	win.SetMonitor(pixelgl.PrimaryMonitor())
+}
+
+// keyboard reads the hex keypad from the window's 0-9 and A-F keys
+type keyboard struct {
+	win *pixelgl.Window
+}
+
+// window keys for each hex key, indexed by nibble
+var keyboardKeys = [16]pixelgl.Button{
+	pixelgl.Key0,
+	pixelgl.Key1,
+	pixelgl.Key2,
+	pixelgl.Key3,
+	pixelgl.Key4,
+	pixelgl.Key5,
+	pixelgl.Key6,
+	pixelgl.Key7,
+	pixelgl.Key8,
+	pixelgl.Key9,
+	pixelgl.KeyA,
+	pixelgl.KeyB,
+	pixelgl.KeyC,
+	pixelgl.KeyD,
+	pixelgl.KeyE,
+	pixelgl.KeyF,
+}
+
+func (k keyboard) heldKeys() [16]bool {
+	var held [16]bool
+	for nibble, key := range keyboardKeys {
+		held[nibble] = k.win.Pressed(key)
+	}
+	return held
+}